@@ -1,36 +1,34 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
+	"os/signal"
 
 	"github.com/kanef1/event-reminder-bot/pkg/app"
-
-	"github.com/joho/godotenv"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Не удалось загрузить .env файл")
-	}
+	// configuration file is optional, environment variables and .env override it
+	file := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or TOML configuration file")
+	flag.Parse()
 
-	token := os.Getenv("TELEGRAM_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_TOKEN не задан")
+	cfg, err := config.Load(*file)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	DB_HOST := os.Getenv("DB_HOST")
-	DB_PORT := os.Getenv("DB_PORT")
-	DB_USER := os.Getenv("DB_USER")
-	DB_PASSWORD := os.Getenv("DB_PASSWORD")
-	DB_NAME := os.Getenv("DB_NAME")
-
-	if DB_HOST == "" || DB_PORT == "" || DB_USER == "" || DB_PASSWORD == "" || DB_NAME == "" {
-		log.Fatal("Не заданы параметры подключения к PostgreSQL")
-	}
-
-	a := app.New(token, DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME)
+	a := app.New(cfg, clock.Real)
 	defer a.Close()
 
-	a.Run()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := a.Run(ctx); err != nil {
+		log.Printf("Ошибка работы бота: %v", err)
+	}
 }
@@ -5,10 +5,12 @@ go 1.24.4
 require github.com/go-telegram/bot v1.16.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/go-pg/pg/v10 v10.15.0
 	github.com/go-pg/urlstruct v1.0.1
 	github.com/joho/godotenv v1.5.1
 	github.com/prometheus/client_golang v1.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,6 +19,7 @@ require (
 	github.com/codemodus/kace v0.5.1 // indirect
 	github.com/go-pg/zerochecker v0.2.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
 	github.com/prometheus/client_model v0.6.2 // indirect
 	github.com/prometheus/common v0.65.0 // indirect
@@ -2,58 +2,123 @@ package app
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
 	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
 	"github.com/kanef1/event-reminder-bot/pkg/botService"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
 	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/embedlog"
 	"github.com/kanef1/event-reminder-bot/pkg/reminder"
+	"github.com/kanef1/event-reminder-bot/pkg/sender"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var (
+	statLogEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_log_events_total",
+		Help: "Number of error and debug log events of database layer.",
+	}, []string{"type"})
+	catchUpEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_catch_up_events_total",
+		Help: "Number of reminders missed while the bot was down by catch-up outcome: late, archived, dropped, skipped or claimed.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(statLogEvents, catchUpEvents)
+	embedlog.SetStatLogEvents(statLogEvents)
+}
+
 type App struct {
-	b          *bot.Bot
-	bm         *botManager.BotManager
-	rm         *reminder.ReminderManager
-	bs         *botService.BotService
-	db         *pg.DB
-	eventsRepo db.EventsRepo
+	cfg    config.Config
+	b      *bot.Bot
+	bm     *botManager.BotManager
+	rm     *reminder.ReminderManager
+	bs     *botService.BotService
+	sender *sender.Sender
+	db     *pg.DB
+	store  storage.EventStore
+	clock  clock.Clock
+	// ready is closed by Run when the bot starts to receive updates
+	ready chan struct{}
 }
 
-func New(token, DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME string) App {
-	var a App
+// New creates App from validated configuration.
+// Bot options are passed to bot.New after default ones, e.g. bot.WithServerURL for fake API in tests.
+func New(cfg config.Config, clk clock.Clock, opts ...bot.Option) App {
+	a := App{cfg: cfg, clock: clk, ready: make(chan struct{})}
 
-	a.db = pg.Connect(&pg.Options{
-		Addr:     DB_HOST + ":" + DB_PORT,
-		User:     DB_USER,
-		Password: DB_PASSWORD,
-		Database: DB_NAME,
-	})
+	if cfg.Storage.Backend == storage.BackendPostgres {
+		pgOpts, err := cfg.Database.Options()
+		if err != nil {
+			log.Fatalf("Ошибка параметров подключения к БД: %v", err)
+		}
+		a.db = pg.Connect(pgOpts)
 
-	database := db.New(a.db)
-	sqlLogger := log.New(os.Stdout, "Q", log.LstdFlags)
-	database.AddQueryHook(db.NewQueryLogger(sqlLogger))
+		database := db.New(a.db)
+		var sqlLogger *log.Logger
+		if cfg.LogLevel == config.LogDebug {
+			sqlLogger = log.New(os.Stdout, "Q", log.LstdFlags)
+		}
+		database.AddQueryHook(db.NewQueryLogger(sqlLogger))
 
-	v, err := database.Version()
+		v, err := database.Version()
+		if err != nil {
+			log.Fatalf("Ошибка подключения к БД: %v", err)
+		}
+		log.Println("Postgres version:", v)
+
+		a.store = storage.NewPostgres(database)
+	} else {
+		store, err := storage.New(cfg.Storage.Backend, cfg.Storage.File)
+		if err != nil {
+			log.Fatalf("Ошибка открытия хранилища: %v", err)
+		}
+		log.Println("Storage:", cfg.Storage.Backend)
+
+		a.store = store
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к БД: %v", err)
+		log.Fatalf("Ошибка загрузки часового пояса: %v", err)
 	}
-	log.Println("Postgres version:", v)
 
-	a.eventsRepo = db.NewEventsRepo(a.db)
+	// bot service is created after the bot, updates are handled only after Run starts the bot
+	var bs *botService.BotService
+	defaultHandler := func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		bs.DefaultHandler(ctx, b, update)
+	}
 
-	b, err := bot.New(token, bot.WithDefaultHandler(botManager.DefaultHandler))
+	b, err := bot.New(cfg.Telegram.Token, append([]bot.Option{bot.WithDefaultHandler(defaultHandler)}, opts...)...)
 	if err != nil {
 		panic(err)
 	}
 	a.b = b
-	a.bm = botManager.NewBotManager(a.b, a.eventsRepo)
-	a.rm = reminder.NewReminderManager(a.bm, a.eventsRepo)
-	a.bs = botService.NewBotService(b, a.bm, a.rm)
+	var limits sender.Limits
+	if cfg.Features.RateLimit {
+		limits = sender.Limits(cfg.Limits)
+	}
+	// API limits are real time limits, so sender does not use clk
+	a.sender = sender.New(b, clock.Real, limits)
+	a.bm = botManager.NewBotManager(a.b, a.sender, a.store, clk, loc)
+	a.rm = reminder.NewReminderManager(a.bm, a.store, clk, botManager.CatchUp(cfg.CatchUp))
+
+	var dialogs botManager.DialogStore = botManager.NewMemoryDialogStore(clk)
+	if cfg.Features.PersistDialogs {
+		dialogs = botManager.NewPersistentDialogStore(a.store, clk)
+	}
+	bs = botService.NewBotService(b, a.bm, a.rm, dialogs)
+	a.bs = bs
 
 	return a
 }
@@ -64,46 +129,150 @@ func (a App) Close() {
 	}
 }
 
-func (a App) Run() error {
-	a.bs.RegisterHandlers()
+// Run starts the bot and blocks until ctx is done. Updates are received by long polling or by webhook
+// depending on ServerConfig.
+func (a App) Run(ctx context.Context) error {
+	if a.cfg.Server.Addr != "" {
+		if err := a.serve(ctx); err != nil {
+			return err
+		}
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
+	a.bs.RegisterHandlers()
+	go a.sender.Run(ctx)
 
-	if err := a.cleanupPastEvents(); err != nil {
-		log.Printf("Ошибка очистки событий: %v", err)
+	if err := a.catchUpPastEvents(ctx); err != nil {
+		log.Printf("Ошибка обработки пропущенных событий: %v", err)
 	}
 
 	a.restoreReminders(ctx)
+	go a.rm.Run(ctx)
 
+	if a.cfg.Server.Webhook() {
+		return a.startWebhook(ctx)
+	}
+
+	close(a.ready)
 	a.b.Start(ctx)
 	return nil
 }
 
-func (a App) cleanupPastEvents() error {
-	_, err := a.db.ExecContext(context.Background(),
-		"DELETE FROM events WHERE \"sendAt\" < NOW()")
-	return err
+// Ready returns channel which is closed when the bot starts to receive updates: after webhook is set
+// or before long polling starts.
+func (a App) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// catchUpClaimed is a catch-up outcome of event delivered or moved by another instance meanwhile.
+const catchUpClaimed = "claimed"
+
+// catchUpPastEvents puts reminders missed within catch-up window to outbox and archives or drops older ones.
+// Recurring events older than window are skipped here and moved to next occurrence by restoreReminders.
+// Every event is claimed in its own transaction, so several bot instances can run it concurrently.
+func (a App) catchUpPastEvents(ctx context.Context) error {
+	status, now := db.EventStatusPending, a.clock.Now()
+	events, err := a.store.EventsByFilters(ctx, &db.EventSearch{StatusID: &status, SendAtTo: &now}, db.PagerNoLimit)
+	if err != nil {
+		return err
+	}
+
+	stats := make(map[string]int)
+	for _, e := range events {
+		var outcome string
+		err := a.store.RunInTransaction(ctx, func(s storage.EventStore) (err error) {
+			outcome, err = a.catchUpEvent(ctx, s, e, now)
+			return err
+		})
+		if err != nil {
+			log.Printf("Ошибка обработки пропущенного события ID=%d: %v", e.ID, err)
+			continue
+		}
+		stats[outcome]++
+		catchUpEvents.WithLabelValues(outcome).Inc()
+	}
+
+	log.Printf("Пропущенные напоминания: в очереди отправки=%d, в истории=%d, удалено=%d, пропущено повторов=%d, обработано другим экземпляром=%d",
+		stats[botManager.CatchUpLate], stats[botManager.CatchUpArchived], stats[botManager.CatchUpDropped],
+		stats[botManager.CatchUpSkipped], stats[catchUpClaimed])
+	return nil
+}
+
+// catchUpEvent claims missed event and applies catch-up policy to it.
+func (a App) catchUpEvent(ctx context.Context, s storage.EventStore, e db.Event, now time.Time) (string, error) {
+	bm := a.bm.WithStore(s)
+
+	event, err := bm.ClaimEvent(ctx, e.UserTgID, e.UserEventID)
+	if errors.Is(err, botManager.ErrEventNotFound) {
+		return catchUpClaimed, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	overdue := now.Sub(event.DateTime)
+	if overdue < 0 {
+		// moved to the future meanwhile
+		return catchUpClaimed, nil
+	}
+
+	// late reminder is sent from outbox by ReminderManager as soon as it starts
+	outcome, _, err := bm.CatchUpEvent(ctx, *event, overdue, botManager.CatchUp(a.cfg.CatchUp))
+	if err != nil {
+		return "", err
+	}
+
+	switch outcome {
+	case botManager.CatchUpLate:
+		log.Printf("Пропущенное напоминание ID=%d поставлено в очередь отправки, опоздание %s", e.ID, overdue.Round(time.Second))
+	case botManager.CatchUpSkipped:
+		log.Printf("Пропущен повтор ID=%d (%s), опоздание %s больше окна", e.ID, event.DateTime, overdue.Round(time.Second))
+	case botManager.CatchUpDropped:
+		log.Printf("Удалено просроченное напоминание ID=%d (%s)", e.ID, event.DateTime)
+	case botManager.CatchUpArchived:
+		log.Printf("Просроченное напоминание ID=%d (%s) перенесено в историю", e.ID, event.DateTime)
+	}
+	return outcome, nil
 }
 
+// restoreReminders moves recurring events missed while the bot was down to their next occurrence.
+// Upcoming events are loaded by ReminderManager itself.
 func (a App) restoreReminders(ctx context.Context) {
-	events, err := a.eventsRepo.EventsByFilters(ctx, &db.EventSearch{}, db.PagerNoLimit)
+	status, now := db.EventStatusPending, a.clock.Now()
+	events, err := a.store.EventsByFilters(ctx, &db.EventSearch{StatusID: &status, SendAtTo: &now}, db.PagerNoLimit)
 	if err != nil {
 		log.Printf("Ошибка восстановления напоминаний: %v", err)
 		return
 	}
 
 	for _, e := range events {
-		if e.SendAt.After(time.Now()) {
-			event := reminder.Event{
-				ID:         e.ID,
-				OriginalID: e.ID,
-				ChatID:     e.UserTgID,
-				Text:       e.Message,
-				DateTime:   e.SendAt,
+		if e.Recurrence == nil {
+			continue
+		}
+
+		err := a.store.RunInTransaction(ctx, func(s storage.EventStore) error {
+			bm := a.bm.WithStore(s)
+
+			event, err := bm.ClaimEvent(ctx, e.UserTgID, e.UserEventID)
+			if errors.Is(err, botManager.ErrEventNotFound) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if event.DateTime.After(a.clock.Now()) {
+				return nil
+			}
+
+			next, err := bm.NextOccurrence(ctx, e.UserTgID, e.UserEventID)
+			if err != nil {
+				return err
+			}
+			if next != nil {
+				log.Printf("Восстановлено напоминание: ID=%d, следующий повтор %s", e.ID, next.DateTime)
 			}
-			a.rm.ScheduleReminder(ctx, event)
-			log.Printf("Восстановлено напоминание: ID=%d", e.ID)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Ошибка расчёта следующего повтора ID=%d: %v", e.ID, err)
 		}
 	}
 }
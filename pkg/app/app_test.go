@@ -0,0 +1,114 @@
+package app_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/apptest"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+)
+
+// testNow is 13:00 in Europe/Moscow, the default time zone of users.
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestCommands(t *testing.T) {
+	const user, stranger = int64(1), int64(2)
+
+	h := apptest.Start(apptest.Config{Now: testNow})
+	t.Cleanup(h.Close)
+
+	send := func(chatID int64, text, want string) {
+		t.Helper()
+
+		call, err := h.Send(chatID, chatID, text)
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		if !strings.Contains(call.Text(), want) {
+			t.Fatalf("%s: reply %q, want %q", text, call.Text(), want)
+		}
+	}
+
+	send(user, "/add 2030-01-01 13:05 встреча", "✅ Событие добавлено на 2030-01-01 13:05 (Europe/Moscow)")
+	send(user, "/add 2030-01-01 13:04 обед", "✅ Событие добавлено на 2030-01-01 13:04")
+	send(user, "/list", "встреча — 2030-01-01 13:05 (ID: 1)")
+
+	// event IDs are numbered per chat, so stranger has no event 2
+	send(stranger, "/delete 2", "❗ Событие с таким ID не найдено")
+	send(user, "/delete 2", "✅ Событие удалено!")
+
+	list, err := h.Send(user, user, "/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(list.Text(), "обед") {
+		t.Errorf("deleted event is listed: %q", list.Text())
+	}
+
+	// fake clock fires timers at the time it is moved to, so it is moved right to the event time
+	h.Clock.Advance(5 * time.Minute)
+	reminder, err := h.API.Wait("sendMessage", apptest.Timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reminder.ChatID() != user || reminder.Text() != "🔔 Напоминание: встреча" {
+		t.Errorf("reminder %q to chat %d, want %q to chat %d", reminder.Text(), reminder.ChatID(), "🔔 Напоминание: встреча", user)
+	}
+
+	if _, err := h.Press(reminder, user, "Готово"); err != nil {
+		t.Fatalf("press done: %v", err)
+	}
+	edit, err := h.API.Wait("editMessageText", apptest.Timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(edit.Text(), "✅ Готово") {
+		t.Errorf("reminder after done: %q", edit.Text())
+	}
+
+	// deleted event does not fire
+	h.Clock.Advance(time.Hour)
+	if call, err := h.API.Wait("sendMessage", time.Second); err == nil {
+		t.Errorf("unexpected message %q", call.Text())
+	}
+}
+
+func TestWebhookDeletedOnlyIfConfigured(t *testing.T) {
+	for _, deleteWebhook := range []bool{false, true} {
+		t.Run(fmt.Sprint("delete=", deleteWebhook), func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Storage.Backend = storage.BackendMemory
+			cfg.Server = config.Server{
+				Addr:          "127.0.0.1:0",
+				MetricsPath:   "/metrics",
+				Updates:       config.UpdatesWebhook,
+				WebhookURL:    "https://bot.example.com/webhook",
+				WebhookSecret: "secret",
+				DeleteWebhook: deleteWebhook,
+			}
+
+			h := apptest.Start(apptest.Config{App: &cfg, Now: testNow})
+			// stopping before the bot gets response to setWebhook would fail it
+			if err := h.Ready(); err != nil {
+				h.Close()
+				t.Fatal(err)
+			}
+			h.Close()
+
+			if got := len(h.API.Calls("setWebhook")); got != 1 {
+				t.Errorf("setWebhook called %d times, want 1", got)
+			}
+
+			want := 0
+			if deleteWebhook {
+				want = 1
+			}
+			if got := len(h.API.Calls("deleteWebhook")); got != want {
+				t.Errorf("deleteWebhook called %d times, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testNow is 13:00 in Europe/Moscow, the default time zone of users.
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// newTestApp returns App on memory store and fake API which is not run, so its startup steps are called by test.
+func newTestApp(t *testing.T, catchUp config.CatchUp) App {
+	t.Helper()
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+
+	cfg := config.Default()
+	cfg.Storage.Backend = storage.BackendMemory
+	cfg.Telegram.Token = telegramtest.Token
+	cfg.CatchUp = catchUp
+
+	a := New(cfg, clock.NewFake(testNow), bot.WithServerURL(api.URL()))
+	t.Cleanup(a.Close)
+
+	return a
+}
+
+func TestStartupCatchUp(t *testing.T) {
+	const chatID = int64(1)
+	// daily at 12:50 Moscow time, 10 minutes before testNow
+	const daily = "FREQ=DAILY;BYHOUR=12;BYMINUTE=50;TZID=Europe/Moscow"
+
+	tests := []struct {
+		name       string
+		sendAt     time.Time
+		recurrence string
+		drop       bool
+
+		outcome string
+		// status and time of event after startup, event is deleted if status is 0
+		status int
+		want   time.Time
+		// outbox is true if late reminder is put to outbox
+		outbox bool
+	}{
+		{
+			name:    "inside window",
+			sendAt:  testNow.Add(-10 * time.Minute),
+			outcome: botManager.CatchUpLate,
+			status:  db.EventStatusSending,
+			want:    testNow.Add(-10 * time.Minute),
+			outbox:  true,
+		},
+		{
+			name:    "outside window",
+			sendAt:  testNow.Add(-2 * time.Hour),
+			outcome: botManager.CatchUpArchived,
+			status:  db.EventStatusFailed,
+			want:    testNow.Add(-2 * time.Hour),
+		},
+		{
+			name:    "outside window dropped",
+			sendAt:  testNow.Add(-2 * time.Hour),
+			drop:    true,
+			outcome: botManager.CatchUpDropped,
+		},
+		{
+			name:       "recurring inside window",
+			sendAt:     testNow.Add(-10 * time.Minute),
+			recurrence: daily,
+			outcome:    botManager.CatchUpLate,
+			status:     db.EventStatusPending,
+			want:       testNow.Add(-10*time.Minute).AddDate(0, 0, 1),
+			outbox:     true,
+		},
+		{
+			name:       "recurring outside window",
+			sendAt:     testNow.Add(-10*time.Minute).AddDate(0, 0, -3),
+			recurrence: daily,
+			outcome:    botManager.CatchUpSkipped,
+			status:     db.EventStatusPending,
+			want:       testNow.Add(-10*time.Minute).AddDate(0, 0, 1),
+		},
+		{
+			name:   "upcoming",
+			sendAt: testNow.Add(10 * time.Minute),
+			status: db.EventStatusPending,
+			want:   testNow.Add(10 * time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a := newTestApp(t, config.CatchUp{Window: time.Hour, Drop: tt.drop})
+
+			dbEvent := &db.Event{UserTgID: chatID, Message: "встреча", SendAt: tt.sendAt, StatusID: db.EventStatusPending}
+			if tt.recurrence != "" {
+				dbEvent.Recurrence = &tt.recurrence
+			}
+			dbEvent, err := a.store.AddEvent(ctx, dbEvent)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var before float64
+			if tt.outcome != "" {
+				before = testutil.ToFloat64(catchUpEvents.WithLabelValues(tt.outcome))
+			}
+
+			// the same steps as in Run
+			if err := a.catchUpPastEvents(ctx); err != nil {
+				t.Fatalf("catchUpPastEvents: %v", err)
+			}
+			a.restoreReminders(ctx)
+
+			if tt.outcome != "" {
+				if got := testutil.ToFloat64(catchUpEvents.WithLabelValues(tt.outcome)) - before; got != 1 {
+					t.Errorf("catch-up metric %q grew by %v, want 1", tt.outcome, got)
+				}
+			}
+
+			event, err := a.store.OneEvent(ctx, &db.EventSearch{ID: &dbEvent.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.status == 0 && event != nil:
+				t.Errorf("event is kept: %+v", *event)
+			case tt.status == 0:
+			case event == nil:
+				t.Errorf("event is deleted")
+			case event.StatusID != tt.status || !event.SendAt.Equal(tt.want):
+				t.Errorf("event status %d at %s, want %d at %s", event.StatusID, event.SendAt, tt.status, tt.want)
+			}
+
+			outbox, err := a.store.OutboxesByFilters(ctx, &db.OutboxSearch{EventID: &dbEvent.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(outbox) > 0; got != tt.outbox {
+				t.Fatalf("outbox %v, want late reminder %t", outbox, tt.outbox)
+			}
+			if tt.outbox && (len(outbox) != 1 || outbox[0].StatusID != db.EventStatusPending || !outbox[0].SendAt.Equal(tt.sendAt)) {
+				t.Errorf("outbox %+v, want one pending message of %s", outbox, tt.sendAt)
+			}
+		})
+	}
+}
+
+func TestStartupCatchUpTwice(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, config.CatchUp{Window: time.Hour})
+
+	dbEvent, err := a.store.AddEvent(ctx, &db.Event{UserTgID: 1, Message: "встреча", SendAt: testNow.Add(-10 * time.Minute), StatusID: db.EventStatusPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restart before late reminder is sent does not queue it again
+	for range 2 {
+		if err := a.catchUpPastEvents(ctx); err != nil {
+			t.Fatalf("catchUpPastEvents: %v", err)
+		}
+		a.restoreReminders(ctx)
+	}
+
+	outbox, err := a.store.OutboxesByFilters(ctx, &db.OutboxSearch{EventID: &dbEvent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 {
+		t.Errorf("outbox %+v, want one message", outbox)
+	}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout is how long HTTP server waits for running requests on stop.
+const shutdownTimeout = 5 * time.Second
+
+// serve starts HTTP server in background, it is stopped when ctx is done.
+func (a App) serve(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("GET "+a.cfg.Server.MetricsPath, promhttp.Handler())
+	if a.cfg.Server.Webhook() {
+		mux.Handle("POST "+a.cfg.Server.WebhookPath(), secretToken(a.cfg.Server.WebhookSecret, a.b.WebhookHandler()))
+	}
+
+	ln, err := net.Listen("tcp", a.cfg.Server.Addr)
+	if err != nil {
+		return fmt.Errorf("ошибка запуска HTTP сервера: %w", err)
+	}
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Ошибка HTTP сервера: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Ошибка остановки HTTP сервера: %v", err)
+		}
+	}()
+
+	log.Printf("HTTP сервер запущен на %s", ln.Addr())
+	return nil
+}
+
+// startWebhook sets webhook and handles updates until ctx is done, then deletes webhook if configured.
+func (a App) startWebhook(ctx context.Context) error {
+	_, err := a.b.SetWebhook(ctx, &bot.SetWebhookParams{URL: a.cfg.Server.WebhookURL, SecretToken: a.cfg.Server.WebhookSecret})
+	if err != nil {
+		return fmt.Errorf("ошибка установки webhook: %w", err)
+	}
+	log.Printf("Webhook установлен: %s", a.cfg.Server.WebhookURL)
+	close(a.ready)
+
+	a.b.StartWebhook(ctx)
+	if !a.cfg.Server.DeleteWebhook {
+		return nil
+	}
+
+	// ctx is done at this point
+	deleteCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if _, err := a.b.DeleteWebhook(deleteCtx, &bot.DeleteWebhookParams{}); err != nil {
+		return fmt.Errorf("ошибка удаления webhook: %w", err)
+	}
+	log.Println("Webhook удалён")
+
+	return nil
+}
+
+// secretToken passes to next only requests with X-Telegram-Bot-Api-Secret-Token header equal to token.
+func secretToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
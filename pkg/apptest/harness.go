@@ -0,0 +1,106 @@
+// Package apptest runs App against fake Telegram API of telegramtest for end-to-end tests.
+package apptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/app"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+// Timeout is how long harness waits for bot replies.
+const Timeout = 5 * time.Second
+
+// Config of harness. Zero Config runs the bot with in-memory store.
+type Config struct {
+	// App is a configuration of the bot, default one with in-memory store if nil. Telegram token is set by harness.
+	// Fake API accepts setWebhook in webhook mode but does not deliver updates to webhook.
+	App *config.Config
+	// Now is a start time of harness clock, current time by default.
+	Now time.Time
+}
+
+// Harness is a running App connected to fake Telegram API.
+type Harness struct {
+	API *telegramtest.Server
+	// Clock drives reminders of App, they fire when it is advanced past their time.
+	Clock *clock.Fake
+
+	app    app.App
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start starts fake API and App, they are stopped by Close.
+func Start(cfg Config) *Harness {
+	appCfg := config.Default()
+	appCfg.Storage.Backend = storage.BackendMemory
+	if cfg.App != nil {
+		appCfg = *cfg.App
+	}
+	appCfg.Telegram.Token = telegramtest.Token
+
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	h := &Harness{API: telegramtest.NewServer(), Clock: clock.NewFake(cfg.Now), done: make(chan struct{})}
+	h.app = app.New(appCfg, h.Clock, bot.WithServerURL(h.API.URL()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go func() {
+		defer close(h.done)
+		h.app.Run(ctx)
+	}()
+
+	return h
+}
+
+// Ready waits until App starts to receive updates, e.g. sets webhook. It fails if App stops or is not ready in Timeout.
+func (h *Harness) Ready() error {
+	select {
+	case <-h.app.Ready():
+		return nil
+	case <-h.done:
+		return errors.New("app stopped")
+	case <-time.After(Timeout):
+		return fmt.Errorf("app is not ready in %s", Timeout)
+	}
+}
+
+// Send sends text from user to chat and returns the next message sent by the bot.
+// Chat is private if chatID equals userID and group otherwise.
+func (h *Harness) Send(chatID, userID int64, text string) (telegramtest.Call, error) {
+	h.API.Message(chatID, userID, text)
+	return h.API.Wait("sendMessage", Timeout)
+}
+
+// Press presses button with text containing button under message of call and returns answer of callback query.
+func (h *Harness) Press(call telegramtest.Call, userID int64, button string) (telegramtest.Call, error) {
+	data := call.Button(button)
+	if data == "" {
+		return telegramtest.Call{}, fmt.Errorf("button %q not found", button)
+	}
+
+	if err := h.API.Callback(call.MessageID(), userID, data); err != nil {
+		return telegramtest.Call{}, err
+	}
+
+	return h.API.Wait("answerCallbackQuery", Timeout)
+}
+
+// Close stops App and fake API.
+func (h *Harness) Close() {
+	h.cancel()
+	<-h.done
+	h.app.Close()
+	h.API.Close()
+}
@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+)
+
+// steps of interactive /add dialog
+const (
+	DialogStepText    = "text"
+	DialogStepDate    = "date"
+	DialogStepTime    = "time"
+	DialogStepConfirm = "confirm"
+)
+
+// callback data of /add dialog keyboards: "add:month:2025-09", "add:date:2025-09-15", "add:hour:9",
+// "add:time:09:30", "add:ok", "add:cancel" and "add:noop" for non-clickable cells.
+const (
+	CallbackDialog = "add:"
+
+	DialogActionMonth  = "month"
+	DialogActionDate   = "date"
+	DialogActionHour   = "hour"
+	DialogActionTime   = "time"
+	DialogActionOK     = "ok"
+	DialogActionCancel = "cancel"
+	DialogActionNoop   = "noop"
+)
+
+// dialogTTL is how long unfinished dialog is kept.
+const dialogTTL = 24 * time.Hour
+
+// dialogMinutes are minutes offered by time picker.
+var dialogMinutes = []int{0, 15, 30, 45}
+
+var monthNames = []string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь",
+	"Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+// Dialog is a state of interactive /add dialog in chat.
+type Dialog struct {
+	ChatID int64
+	// UserID is a member who started dialog, in groups only this member can continue it.
+	UserID int64
+	Step   string
+	Text   string
+	// SendAt holds chosen date on time step and full event time on confirm step.
+	SendAt    time.Time
+	UpdatedAt time.Time
+}
+
+// DialogStore keeps dialog states by chat.
+type DialogStore interface {
+	// Dialog returns active dialog of chat or nil.
+	Dialog(ctx context.Context, chatID int64) (*Dialog, error)
+	SaveDialog(ctx context.Context, d Dialog) error
+	DeleteDialog(ctx context.Context, chatID int64) error
+}
+
+// MemoryDialogStore keeps dialogs in memory, they are lost on restart.
+type MemoryDialogStore struct {
+	mu      sync.Mutex
+	dialogs map[int64]Dialog
+	clock   clock.Clock
+}
+
+func NewMemoryDialogStore(clk clock.Clock) *MemoryDialogStore {
+	return &MemoryDialogStore{dialogs: make(map[int64]Dialog), clock: clk}
+}
+
+func (s *MemoryDialogStore) Dialog(_ context.Context, chatID int64) (*Dialog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.dialogs[chatID]
+	if !ok {
+		return nil, nil
+	}
+
+	if s.clock.Now().Sub(d.UpdatedAt) > dialogTTL {
+		delete(s.dialogs, chatID)
+		return nil, nil
+	}
+
+	return &d, nil
+}
+
+func (s *MemoryDialogStore) SaveDialog(_ context.Context, d Dialog) error {
+	d.UpdatedAt = s.clock.Now()
+
+	s.mu.Lock()
+	s.dialogs[d.ChatID] = d
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryDialogStore) DeleteDialog(_ context.Context, chatID int64) error {
+	s.mu.Lock()
+	delete(s.dialogs, chatID)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// PersistentDialogStore keeps dialogs in event store, so they survive restarts and, with PostgreSQL,
+// are shared between instances.
+type PersistentDialogStore struct {
+	store storage.EventStore
+	clock clock.Clock
+}
+
+func NewPersistentDialogStore(store storage.EventStore, clk clock.Clock) PersistentDialogStore {
+	return PersistentDialogStore{store: store, clock: clk}
+}
+
+func (s PersistentDialogStore) Dialog(ctx context.Context, chatID int64) (*Dialog, error) {
+	dbDialog, err := s.store.DialogByID(ctx, chatID)
+	if err != nil || dbDialog == nil {
+		return nil, err
+	}
+
+	if s.clock.Now().Sub(dbDialog.UpdatedAt) > dialogTTL {
+		return nil, s.store.DeleteDialog(ctx, chatID)
+	}
+
+	d := &Dialog{
+		ChatID:    dbDialog.ID,
+		Step:      dbDialog.Step,
+		UpdatedAt: dbDialog.UpdatedAt,
+	}
+	if dbDialog.CreatorTgID != nil {
+		d.UserID = *dbDialog.CreatorTgID
+	}
+	if dbDialog.Message != nil {
+		d.Text = *dbDialog.Message
+	}
+	if dbDialog.SendAt != nil {
+		d.SendAt = *dbDialog.SendAt
+	}
+
+	return d, nil
+}
+
+func (s PersistentDialogStore) SaveDialog(ctx context.Context, d Dialog) error {
+	// PostgreSQL sets update time by itself, other stores keep time of the bot clock used to expire dialogs
+	dbDialog := &db.Dialog{ID: d.ChatID, Step: d.Step, CreatorTgID: &d.UserID, UpdatedAt: s.clock.Now()}
+	if d.Text != "" {
+		dbDialog.Message = &d.Text
+	}
+	if !d.SendAt.IsZero() {
+		dbDialog.SendAt = &d.SendAt
+	}
+
+	return s.store.SaveDialog(ctx, dbDialog)
+}
+
+func (s PersistentDialogStore) DeleteDialog(ctx context.Context, chatID int64) error {
+	return s.store.DeleteDialog(ctx, chatID)
+}
+
+// ParseDialogData parses "add:<action>[:<value>]" callback data.
+func ParseDialogData(data string) (action, value string) {
+	action, value, _ = strings.Cut(strings.TrimPrefix(data, CallbackDialog), ":")
+	return action, value
+}
+
+func dialogButton(text, action, value string) models.InlineKeyboardButton {
+	data := CallbackDialog + action
+	if value != "" {
+		data += ":" + value
+	}
+
+	return models.InlineKeyboardButton{Text: text, CallbackData: data}
+}
+
+func cancelRow() []models.InlineKeyboardButton {
+	return []models.InlineKeyboardButton{dialogButton("❌ Отмена", DialogActionCancel, "")}
+}
+
+// CalendarKeyboard returns inline calendar of month. Days before today are not clickable.
+func CalendarKeyboard(month, today time.Time) *models.InlineKeyboardMarkup {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, month.Location())
+	noop := dialogButton(" ", DialogActionNoop, "")
+
+	prev := noop
+	if first.After(today) {
+		prev = dialogButton("‹", DialogActionMonth, first.AddDate(0, -1, 0).Format("2006-01"))
+	}
+	next := dialogButton("›", DialogActionMonth, first.AddDate(0, 1, 0).Format("2006-01"))
+	title := dialogButton(fmt.Sprintf("%s %d", monthNames[first.Month()-1], first.Year()), DialogActionNoop, "")
+
+	rows := [][]models.InlineKeyboardButton{{prev, title, next}}
+
+	var weekdays []models.InlineKeyboardButton
+	for _, wd := range []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"} {
+		weekdays = append(weekdays, dialogButton(wd, DialogActionNoop, ""))
+	}
+	rows = append(rows, weekdays)
+
+	// weeks start on Monday
+	week := make([]models.InlineKeyboardButton, (int(first.Weekday())+6)%7)
+	for i := range week {
+		week[i] = noop
+	}
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		if day.Before(today) {
+			week = append(week, noop)
+		} else {
+			week = append(week, dialogButton(strconv.Itoa(day.Day()), DialogActionDate, day.Format("2006-01-02")))
+		}
+
+		if len(week) == 7 {
+			rows = append(rows, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		for len(week) < 7 {
+			week = append(week, noop)
+		}
+		rows = append(rows, week)
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: append(rows, cancelRow())}
+}
+
+// HourKeyboard returns hour picker of time step.
+func HourKeyboard() *models.InlineKeyboardMarkup {
+	var rows [][]models.InlineKeyboardButton
+	for h := 0; h < 24; h += 6 {
+		var row []models.InlineKeyboardButton
+		for i := h; i < h+6; i++ {
+			row = append(row, dialogButton(fmt.Sprintf("%02d", i), DialogActionHour, strconv.Itoa(i)))
+		}
+		rows = append(rows, row)
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: append(rows, cancelRow())}
+}
+
+// MinuteKeyboard returns minute picker for chosen hour.
+func MinuteKeyboard(hour int) *models.InlineKeyboardMarkup {
+	var row []models.InlineKeyboardButton
+	for _, m := range dialogMinutes {
+		t := fmt.Sprintf("%02d:%02d", hour, m)
+		row = append(row, dialogButton(t, DialogActionTime, t))
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{row, cancelRow()}}
+}
+
+// ConfirmKeyboard returns keyboard of confirm step.
+func ConfirmKeyboard() *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{dialogButton("✅ Сохранить", DialogActionOK, ""), dialogButton("❌ Отмена", DialogActionCancel, "")},
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+)
+
+// ErrForbidden is returned when user may not change event or settings of group chat.
+var ErrForbidden = errors.New("forbidden")
+
+// mentionPrefix marks a chat member in event text: "@alice".
+const mentionPrefix = "@"
+
+// isMention reports whether word is a Telegram username mention: "@" followed by 5-32 letters, digits or "_".
+func isMention(word string) bool {
+	name := strings.TrimPrefix(word, mentionPrefix)
+	if name == word || len(name) < 5 || len(name) > 32 {
+		return false
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func appendMention(mentions []string, word string) []string {
+	for _, m := range mentions {
+		if strings.EqualFold(m, word) {
+			return mentions
+		}
+	}
+
+	return append(mentions, word)
+}
+
+// mentionsLine returns "\n@alice @bob" to notify mentioned members and empty string otherwise.
+func mentionsLine(mentions []string) string {
+	if len(mentions) == 0 {
+		return ""
+	}
+
+	return "\n" + strings.Join(mentions, " ")
+}
+
+// SenderID returns ID of user who sent message, or chat ID for messages sent on behalf of chat.
+func SenderID(msg *models.Message) int64 {
+	if msg.From != nil {
+		return msg.From.ID
+	}
+
+	return msg.Chat.ID
+}
+
+// IsGroup reports whether chat is a group or supergroup.
+func IsGroup(chat models.Chat) bool {
+	return chat.Type == models.ChatTypeGroup || chat.Type == models.ChatTypeSupergroup
+}
+
+// checkAccess allows user to change event if user created it or is an admin of the chat.
+// In private chats chat ID equals user ID, so events added before creator was stored belong to the chat owner.
+func (bm BotManager) checkAccess(ctx context.Context, event *db.Event, userID int64) error {
+	if event.CreatorTgID != nil && *event.CreatorTgID == userID {
+		return nil
+	}
+
+	if event.CreatorTgID == nil && event.UserTgID == userID {
+		return nil
+	}
+
+	return bm.checkAdmin(ctx, event.UserTgID, userID)
+}
+
+// checkAdmin allows user to change settings of chat if it is user's private chat or user is an admin of the chat.
+func (bm BotManager) checkAdmin(ctx context.Context, chatID, userID int64) error {
+	if chatID == userID {
+		return nil
+	}
+
+	member, err := bm.b.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: userID})
+	if err != nil {
+		return err
+	}
+
+	if member.Type == models.ChatMemberTypeOwner || member.Type == models.ChatMemberTypeAdministrator {
+		return nil
+	}
+
+	return ErrForbidden
+}
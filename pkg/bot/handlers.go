@@ -2,245 +2,738 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/dateparse"
 	"github.com/kanef1/event-reminder-bot/pkg/db"
 	"github.com/kanef1/event-reminder-bot/pkg/model"
+	"github.com/kanef1/event-reminder-bot/pkg/recurrence"
+	"github.com/kanef1/event-reminder-bot/pkg/sender"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
 )
 
-func DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
 	if update.Message == nil {
 		return
 	}
-	b.SendMessage(ctx, &bot.SendMessageParams{
+
+	// in groups bot sees members' conversation, only unknown commands are answered
+	if IsGroup(update.Message.Chat) && !strings.HasPrefix(update.Message.Text, "/") {
+		return
+	}
+	bm.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
 		Text:   "Нет такой команды, используйте /help чтобы посмотреть доступные команды команд",
 	})
 }
 
-func StartHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	b.SendMessage(ctx, &bot.SendMessageParams{
+func StartHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	bm.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
 		Text: "Добрый день, данный бот предназначен для простого планирования.\n" +
 			"Список умений:\n" +
 			"Добавить событие: /add 2025-08-08 21:05 <Текст>\n" +
+			"Пошагово с календарём: /add, отмена — /cancel\n" +
+			"Можно проще: /add завтра в 9 <Текст>, /add через 2 часа <Текст>, /add next friday 18:00 <Текст>\n" +
+			"Заранее напомнить: /add 2025-08-08 21:05 -1d,-1h <Текст>\n" +
+			"С тегами: /add 2025-08-08 21:05 #work #billing <Текст>\n" +
+			"Упомянуть участников группы: /add 2025-08-08 21:05 @alice @bob <Текст>\n" +
+			"Повторяющееся событие: /add every mon,wed 09:30 <Текст> (day, weekday, mon..sun, 1,15)\n" +
 			"Список событий: /list \n" +
+			"История напоминаний: /history\n" +
+			"Изменить событие: /edit id 2025-08-08 21:05 или /edit id text <Текст>\n" +
 			"Удалить событие: /delete id\n" +
+			"Часовой пояс: /timezone Europe/Berlin\n" +
 			"Список команд: /help",
 	})
 }
 
-func HelpHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	b.SendMessage(ctx, &bot.SendMessageParams{
+func HelpHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	bm.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
 		Text: "Список умений:\n" +
 			"Добавить событие: /add 2025-08-08 21:05 <Текст>\n" +
-			"Список событий: /list\n" +
+			"Пошагово с календарём: /add, отмена — /cancel\n" +
+			"Можно проще: /add завтра в 9 <Текст>, /add через 2 часа <Текст>, /add next friday 18:00 <Текст>\n" +
+			"Заранее напомнить: /add 2025-08-08 21:05 -1d,-1h <Текст>\n" +
+			"С тегами: /add 2025-08-08 21:05 #work #billing <Текст>\n" +
+			"Упомянуть участников группы: /add 2025-08-08 21:05 @alice @bob <Текст>\n" +
+			"Повторяющееся событие: /add every mon,wed 09:30 <Текст> (day, weekday, mon..sun, 1,15)\n" +
+			"Список событий: /list или /list 2025-09-01..2025-09-07\n" +
+			"По тегам: /list #work или /list #work #home (любой из тегов)\n" +
+			"На сегодня, завтра, неделю: /today, /tomorrow, /week\n" +
+			"Поиск: /find <Текст>\n" +
+			"История напоминаний: /history\n" +
+			"Изменить событие: /edit id 2025-08-08 21:05 или /edit id text <Текст>\n" +
 			"Удалить событие: /delete id\n" +
+			"Часовой пояс: /timezone Europe/Berlin\n" +
 			"Список команд: /help",
 	})
 }
 
-func DeleteHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
-	args := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/delete"))
+func TimezoneHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	args := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/timezone"))
 	if args == "" {
-		b.SendMessage(ctx, &bot.SendMessageParams{
+		bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❗ Укажите ID события, например: /delete 123",
+			Text: fmt.Sprintf("🕒 Ваш часовой пояс: %s\nИзменить: /timezone Europe/Berlin",
+				bm.Location(ctx, update.Message.Chat.ID)),
 		})
 		return
 	}
 
-	id, err := strconv.Atoi(args)
+	loc, err := bm.SetTimezone(ctx, update.Message.Chat.ID, SenderID(update.Message), args)
 	if err != nil {
-		b.SendMessage(ctx, &bot.SendMessageParams{
+		text := "❌ Ошибка при сохранении часового пояса"
+		if errors.Is(err, ErrForbidden) {
+			text = "⛔ Изменить часовой пояс группы может только администратор чата"
+		} else if err.Error() == "invalid_timezone" {
+			text = "❗ Неизвестный часовой пояс, используйте формат IANA, например: /timezone Europe/Berlin"
+		} else {
+			log.Printf("Ошибка сохранения часового пояса: %v", err)
+		}
+
+		bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❗ ID должен быть числом",
+			Text:   text,
 		})
 		return
 	}
 
-	err = bm.DeleteEventByID(ctx, id)
+	bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   "✅ Часовой пояс установлен: " + loc.String(),
+	})
+}
+
+func HistoryHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	events, err := bm.GetUserHistory(ctx, update.Message.Chat.ID)
 	if err != nil {
-		log.Printf("Ошибка удаления события: %v", err)
-		b.SendMessage(ctx, &bot.SendMessageParams{
+		log.Printf("Ошибка загрузки истории: %v", err)
+		bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❌ Ошибка при загрузке истории",
+		})
+		return
+	}
+
+	if len(events) == 0 {
+		bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Ошибка при удалении события",
+			Text:   "🔍 История пуста",
 		})
 		return
 	}
 
-	b.SendMessage(ctx, &bot.SendMessageParams{
+	var msg strings.Builder
+	msg.WriteString("🗂 История напоминаний (от последних):\n\n")
+	for _, e := range events {
+		if e.SentAt != nil {
+			msg.WriteString(fmt.Sprintf("✅ %s — отправлено %s\n", e.Text, e.SentAt.Format("2006-01-02 15:04")))
+		} else {
+			msg.WriteString(fmt.Sprintf("❌ %s — не доставлено (%s)\n", e.Text, e.DateTime.Format("2006-01-02 15:04")))
+		}
+	}
+
+	bm.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
-		Text:   "✅ Событие удалено!",
+		Text:   msg.String(),
 	})
 }
 
+// ListHandler shows events: "/list" or "/list 2025-09-01..2025-09-07".
 func ListHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
-	events, err := bm.GetUserEvents(ctx, update.Message.Chat.ID)
-	if err != nil {
-		log.Printf("Ошибка загрузки событий: %v", err)
-		b.SendMessage(ctx, &bot.SendMessageParams{
+	sendList(ctx, b, update, bm, strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/list")))
+}
+
+// FindHandler shows events containing text: "/find врач".
+func FindHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	query := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/find"))
+	if query == "" {
+		bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Ошибка при загрузке событий",
+			Text:   "❗ Укажите текст для поиска, например: /find врач",
 		})
 		return
 	}
 
-	if len(events) == 0 {
-		b.SendMessage(ctx, &bot.SendMessageParams{
+	sendList(ctx, b, update, bm, FilterFind+query)
+}
+
+// PeriodHandler shows events of /today, /tomorrow or /week.
+func PeriodHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	sendList(ctx, b, update, bm, strings.TrimPrefix(update.Message.Text, "/"))
+}
+
+// sendList sends first page of events matching filter spec.
+func sendList(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager, spec string) {
+	text, markup, err := bm.ListPage(ctx, update.Message.Chat.ID, spec, 1)
+	if err != nil && err.Error() == "invalid_filter" {
+		bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ Недопустимый фильтр (используйте /list 2025-09-01..2025-09-07 или /list #work)",
+		})
+		return
+	} else if errors.Is(err, ErrFilterTooLong) {
+		bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ Слишком длинный фильтр, сократите текст поиска или число тегов",
+		})
+		return
+	} else if err != nil {
+		log.Printf("Ошибка загрузки событий: %v", err)
+		bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "🔍 Нет событий",
+			Text:   "❌ Ошибка при загрузке событий",
 		})
 		return
 	}
 
-	var msg strings.Builder
-	msg.WriteString("📅 Список событий (от ближайших):\n\n")
-	for i, e := range events {
-		msg.WriteString(fmt.Sprintf(
-			"%d. %s — %s (ID: %d)\n",
-			i+1,
-			e.Text,
-			e.DateTime.Format("2006-01-02 15:04"),
-			e.ID,
-		))
-	}
-
-	b.SendMessage(ctx, &bot.SendMessageParams{
+	params := &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
-		Text:   msg.String(),
-	})
+		Text:   text,
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+
+	bm.SendMessage(ctx, params)
+}
+
+// recurrenceSuffix returns " 🔁 <description>" for recurring events and empty string otherwise.
+func recurrenceSuffix(rrule string) string {
+	if rrule == "" {
+		return ""
+	}
+
+	rule, err := recurrence.Parse(rrule)
+	if err != nil {
+		return " 🔁"
+	}
+
+	return " 🔁 " + rule.Describe()
 }
 
+// ErrEventNotFound is returned when event does not exist or belongs to another chat.
+var ErrEventNotFound = errors.New("event_not_found")
+
 type BotManager struct {
-	b          *bot.Bot
-	eventsRepo db.EventsRepo
+	b      *bot.Bot
+	sender *sender.Sender
+	store  storage.EventStore
+	clock  clock.Clock
+	// location is a time zone of users who have not set their own one
+	location *time.Location
 }
 
-func NewBotManager(b *bot.Bot, eventsRepo db.EventsRepo) *BotManager {
-	return &BotManager{b: b, eventsRepo: eventsRepo}
+func NewBotManager(b *bot.Bot, snd *sender.Sender, store storage.EventStore, clk clock.Clock, loc *time.Location) *BotManager {
+	return &BotManager{b: b, sender: snd, store: store, clock: clk, location: loc}
 }
 
-func (bm BotManager) SendReminder(ctx context.Context, chatID int64, text string) {
-	bm.b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   "🔔 Напоминание: " + text,
-	})
+// SendMessage sends reply to user through rate limiter, after queued reminders.
+func (bm BotManager) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	return bm.sender.SendMessage(ctx, sender.PriorityReply, params)
+}
+
+// EditMessageText edits message of bot through rate limiter, like SendMessage.
+func (bm BotManager) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	return bm.sender.EditMessageText(ctx, sender.PriorityReply, params)
+}
+
+// EditMessageReplyMarkup edits keyboard of message of bot through rate limiter, like SendMessage.
+func (bm BotManager) EditMessageReplyMarkup(ctx context.Context, params *bot.EditMessageReplyMarkupParams) (*models.Message, error) {
+	return bm.sender.EditMessageReplyMarkup(ctx, sender.PriorityReply, params)
+}
+
+// AnswerCallbackQuery answers button press through rate limiter, like SendMessage.
+func (bm BotManager) AnswerCallbackQuery(ctx context.Context, params *bot.AnswerCallbackQueryParams) (bool, error) {
+	return bm.sender.AnswerCallbackQuery(ctx, sender.PriorityReply, params)
+}
+
+// Now returns current time of BotManager clock.
+func (bm BotManager) Now() time.Time {
+	return bm.clock.Now()
+}
+
+// WithStore returns BotManager copy that works with events in store, e.g. in transaction of storage.EventStore.
+func (bm BotManager) WithStore(store storage.EventStore) BotManager {
+	bm.store = store
+	return bm
+}
+
+// AddEvent adds event from "<date> <text>", where date is natural-language expression
+// like "завтра в 9" or "in 30 min" or strict "YYYY-MM-DD HH:MM".
+func (bm BotManager) AddEvent(ctx context.Context, chatId, userId int64, args string) (*model.Event, error) {
+	loc := bm.Location(ctx, chatId)
+
+	dt, rest, err := dateparse.Parse(args, bm.Now().In(loc))
+	if err != nil {
+		parts := strings.SplitN(args, " ", 3)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid_format")
+		}
+
+		dt, err = time.ParseInLocation("2006-01-02 15:04", parts[0]+" "+parts[1], loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid_format")
+		}
+		rest = parts[2]
+	}
+
+	offsets, text := splitOffsets(rest)
+	tags, mentions, text := splitLabels(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty_text")
+	}
+
+	if dt.Before(bm.Now()) {
+		return nil, fmt.Errorf("past_date")
+	}
+
+	event := &db.Event{
+		UserTgID:    chatId,
+		Message:     text,
+		SendAt:      dt,
+		StatusID:    db.EventStatusPending,
+		Tags:        tags,
+		Mentions:    mentions,
+		CreatorTgID: &userId,
+	}
+
+	return bm.addEvent(ctx, event, offsets, dt.Location())
+}
+
+// AddEventAt adds one-shot event at dt without advance notifications, text may start with tags.
+func (bm BotManager) AddEventAt(ctx context.Context, chatId, userId int64, dt time.Time, text string) (*model.Event, error) {
+	if dt.Before(bm.Now()) {
+		return nil, fmt.Errorf("past_date")
+	}
+
+	tags, mentions, text := splitLabels(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty_text")
+	}
+
+	event := &db.Event{
+		UserTgID:    chatId,
+		Message:     text,
+		SendAt:      dt,
+		StatusID:    db.EventStatusPending,
+		Tags:        tags,
+		Mentions:    mentions,
+		CreatorTgID: &userId,
+	}
+
+	return bm.addEvent(ctx, event, nil, dt.Location())
+}
+
+// addEvent stores event with its advance notifications.
+func (bm BotManager) addEvent(ctx context.Context, event *db.Event, offsets []time.Duration, loc *time.Location) (*model.Event, error) {
+	addedEvent, err := bm.store.AddEvent(ctx, event)
+	if err != nil {
+		log.Printf("Ошибка сохранения события: %v", err)
+		return nil, err
+	}
+
+	result := newEvent(*addedEvent, loc)
+	result.Notifications, err = bm.addNotifications(ctx, addedEvent, offsets, loc)
+	if err != nil {
+		log.Printf("Ошибка сохранения уведомлений события: %v", err)
+		return nil, err
+	}
+
+	eventsCreated.Inc()
+	return &result, nil
 }
 
-func (bm BotManager) AddEvent(ctx context.Context, chatId int64, parts []string) (*model.Event, error) {
-	datePart := parts[0]
+// AddRecurringEvent adds event repeated by rule from "/add every <spec> HH:MM <text>".
+func (bm BotManager) AddRecurringEvent(ctx context.Context, chatId, userId int64, parts []string) (*model.Event, error) {
+	specPart := parts[0]
 	timePart := parts[1]
-	text := parts[2]
+	offsets, text := splitOffsets(parts[2])
+	tags, mentions, text := splitLabels(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty_text")
+	}
 
-	loc, err := time.LoadLocation("Europe/Moscow")
+	rule, err := recurrence.ParseSpec(specPart)
 	if err != nil {
-		log.Println("Ошибка загрузки часового пояса:", err)
-		loc = time.Local
+		return nil, fmt.Errorf("invalid_recurrence")
 	}
 
-	dt, err := time.ParseInLocation("2006-01-02 15:04", datePart+" "+timePart, loc)
+	tod, err := time.Parse("15:04", timePart)
 	if err != nil {
 		return nil, fmt.Errorf("invalid_format")
 	}
 
-	if dt.Before(time.Now()) {
-		return nil, fmt.Errorf("past_date")
+	rule = rule.At(tod.Hour(), tod.Minute(), bm.Location(ctx, chatId))
+	dt := rule.Next(bm.Now())
+	if dt.IsZero() {
+		return nil, fmt.Errorf("invalid_recurrence")
 	}
 
+	rrule := rule.String()
 	event := &db.Event{
-		UserTgID: chatId,
-		Message:  text,
-		SendAt:   dt,
+		UserTgID:    chatId,
+		Message:     text,
+		SendAt:      dt,
+		Recurrence:  &rrule,
+		StatusID:    db.EventStatusPending,
+		Tags:        tags,
+		Mentions:    mentions,
+		CreatorTgID: &userId,
 	}
 
-	addedEvent, err := bm.eventsRepo.AddEvent(ctx, event)
+	return bm.addEvent(ctx, event, offsets, dt.Location())
+}
+
+// NextOccurrence moves recurring event to its next occurrence after now.
+// It returns nil if event is not recurring.
+func (bm BotManager) NextOccurrence(ctx context.Context, chatID int64, id int) (*model.Event, error) {
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbEvent.Recurrence == nil {
+		return nil, nil
+	}
+
+	rule, err := recurrence.Parse(*dbEvent.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := bm.Location(ctx, dbEvent.UserTgID)
+	if rule.Location == nil {
+		// Rules stored without time of day get it from the current occurrence once.
+		at := dbEvent.SendAt.In(loc)
+		rule = rule.At(at.Hour(), at.Minute(), loc)
+		rrule := rule.String()
+		dbEvent.Recurrence = &rrule
+	}
+
+	from := dbEvent.SendAt
+	if now := bm.Now(); now.After(from) {
+		from = now
+	}
+
+	next := rule.Next(from)
+	if next.IsZero() {
+		return nil, fmt.Errorf("no next occurrence for rule %s", *dbEvent.Recurrence)
+	}
+
+	dbEvent.SendAt = next
+	if _, err := bm.store.UpdateEvent(ctx, dbEvent); err != nil {
+		return nil, err
+	}
+
+	event := newEvent(*dbEvent, loc)
+	event.Notifications, err = bm.rescheduleNotifications(ctx, dbEvent, loc)
 	if err != nil {
-		log.Printf("Ошибка сохранения события: %v", err)
 		return nil, err
 	}
 
-	return &model.Event{
-		ID:         addedEvent.ID,
-		OriginalID: addedEvent.ID,
-		ChatID:     addedEvent.UserTgID,
-		Text:       addedEvent.Message,
-		DateTime:   addedEvent.SendAt,
-	}, nil
+	return &event, nil
 }
 
-func (bm BotManager) DeleteEventByID(ctx context.Context, id int) error {
-	// Получаем событие из базы данных
-	event, err := bm.eventsRepo.EventByID(ctx, id)
+// UpdateEventTime moves event to new date and time in user time zone on behalf of user.
+func (bm BotManager) UpdateEventTime(ctx context.Context, chatID, userID int64, id int, datePart, timePart string) (*model.Event, error) {
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if event == nil {
-		return fmt.Errorf("event not found")
+	if err := bm.checkAccess(ctx, dbEvent, userID); err != nil {
+		return nil, err
 	}
 
-	// Удаляем событие из базы данных
-	deleted, err := bm.eventsRepo.DeleteEvent(ctx, id)
+	dt, err := time.ParseInLocation("2006-01-02 15:04", datePart+" "+timePart, bm.Location(ctx, chatID))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid_format")
+	}
+
+	if dt.Before(bm.Now()) {
+		return nil, fmt.Errorf("past_date")
+	}
+
+	if dbEvent.Recurrence != nil {
+		rule, err := recurrence.Parse(*dbEvent.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		rrule := rule.At(dt.Hour(), dt.Minute(), dt.Location()).String()
+		dbEvent.Recurrence = &rrule
+	}
+
+	dbEvent.SendAt = dt
+	event, err := bm.updateEvent(ctx, dbEvent, dt.Location())
+	if err != nil {
+		return nil, err
 	}
 
-	if !deleted {
-		return fmt.Errorf("event not found")
+	event.Notifications, err = bm.rescheduleNotifications(ctx, dbEvent, dt.Location())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return event, nil
 }
 
-func (bm BotManager) GetUserEvents(ctx context.Context, chatID int64) ([]model.Event, error) {
-	search := &db.EventSearch{UserTgID: &chatID}
-	dbEvents, err := bm.eventsRepo.EventsByFilters(ctx, search, db.PagerNoLimit)
+// UpdateEventText changes event text on behalf of user.
+func (bm BotManager) UpdateEventText(ctx context.Context, chatID, userID int64, id int, text string) (*model.Event, error) {
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
 	if err != nil {
 		return nil, err
 	}
 
-	sort.Slice(dbEvents, func(i, j int) bool {
-		return dbEvents[i].SendAt.Before(dbEvents[j].SendAt)
-	})
+	if err := bm.checkAccess(ctx, dbEvent, userID); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty_text")
+	}
+
+	loc := bm.Location(ctx, chatID)
+	dbEvent.Message = text
+	event, err := bm.updateEvent(ctx, dbEvent, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := bm.eventNotifications(ctx, []int{dbEvent.ID}, loc)
+	if err != nil {
+		return nil, err
+	}
+	event.Notifications = notifications[dbEvent.ID]
+
+	return event, nil
+}
+
+func (bm BotManager) updateEvent(ctx context.Context, dbEvent *db.Event, loc *time.Location) (*model.Event, error) {
+	updated, err := bm.store.UpdateEvent(ctx, dbEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrEventNotFound
+	}
+
+	event := newEvent(*dbEvent, loc)
+	return &event, nil
+}
+
+// DeleteEventByID cancels event on behalf of user, in group chats only creator of event or admins may do it.
+// Caller must cancel queued reminders of returned event.
+func (bm BotManager) DeleteEventByID(ctx context.Context, chatID, userID int64, id int) (*model.Event, error) {
+	// Получаем событие пользователя из базы данных
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bm.checkAccess(ctx, dbEvent, userID); err != nil {
+		return nil, err
+	}
+
+	// Отменяем событие, оставляя его в истории
+	dbEvent.StatusID = db.EventStatusCancelled
+	event, err := bm.updateEvent(ctx, dbEvent, bm.Location(ctx, chatID))
+	if err != nil {
+		return nil, err
+	}
+
+	eventsDeleted.Inc()
+	return event, nil
+}
+
+// MarkEventFailed moves one-shot event to failed status. Recurring events stay pending.
+func (bm BotManager) MarkEventFailed(ctx context.Context, chatID int64, id int) error {
+	event, err := bm.userEvent(ctx, chatID, id)
+	if err != nil {
+		return err
+	}
+
+	if event.Recurrence != nil {
+		return nil
+	}
+
+	event.StatusID = db.EventStatusFailed
+	_, err = bm.updateEvent(ctx, event, time.UTC)
+	return err
+}
+
+// GetUserHistory returns last delivered and failed events of user, newest first.
+func (bm BotManager) GetUserHistory(ctx context.Context, chatID int64) ([]model.Event, error) {
+	dbEvents, err := bm.store.EventHistory(ctx, chatID, db.PagerDefault)
+	if err != nil {
+		return nil, err
+	}
 
+	loc := bm.Location(ctx, chatID)
 	events := make([]model.Event, len(dbEvents))
 	for i, dbEvent := range dbEvents {
-		events[i] = model.Event{
-			ID:         dbEvent.ID,
-			OriginalID: dbEvent.ID,
-			ChatID:     dbEvent.UserTgID,
-			Text:       dbEvent.Message,
-			DateTime:   dbEvent.SendAt,
-		}
+		events[i] = newEvent(dbEvent, loc)
 	}
 
 	return events, nil
 }
 
-func (bm BotManager) GetEventByID(ctx context.Context, id int) (*model.Event, error) {
-	dbEvent, err := bm.eventsRepo.EventByID(ctx, id)
+// GetUserEvents returns page of pending events matching filter sorted by time and total count of matching events.
+func (bm BotManager) GetUserEvents(ctx context.Context, chatID int64, filter ListFilter, pager db.Pager) ([]model.Event, int, error) {
+	search := filter.search()
+	status := db.EventStatusPending
+	search.UserTgID, search.StatusID = &chatID, &status
+
+	total, err := bm.store.CountEvents(ctx, search)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dbEvents, err := bm.store.EventsByFilters(ctx, search, pager)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	loc := bm.Location(ctx, chatID)
+	ids := make([]int, len(dbEvents))
+	for i := range dbEvents {
+		ids[i] = dbEvents[i].ID
+	}
+
+	notifications, err := bm.eventNotifications(ctx, ids, loc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events := make([]model.Event, len(dbEvents))
+	for i, dbEvent := range dbEvents {
+		events[i] = newEvent(dbEvent, loc)
+		events[i].Notifications = notifications[dbEvent.ID]
+	}
+
+	return events, total, nil
+}
+
+func (bm BotManager) GetEventByID(ctx context.Context, chatID int64, id int) (*model.Event, error) {
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(*dbEvent, bm.Location(ctx, chatID))
+	return &event, nil
+}
+
+// ClaimEvent locks pending event row for delivery. It returns ErrEventNotFound if event is
+// not pending or is locked by another transaction, so BotManager must be bound to transaction.
+func (bm BotManager) ClaimEvent(ctx context.Context, chatID int64, id int) (*model.Event, error) {
+	status := db.EventStatusPending
+	dbEvent, err := bm.store.ClaimEvent(ctx, &db.EventSearch{UserEventID: &id, UserTgID: &chatID, StatusID: &status})
+	if err != nil {
+		return nil, err
+	} else if dbEvent == nil {
+		return nil, ErrEventNotFound
+	}
+
+	event := newEvent(*dbEvent, bm.Location(ctx, chatID))
+	return &event, nil
+}
+
+// userEvent returns pending event by per-user ID only if it belongs to chatID, otherwise ErrEventNotFound.
+func (bm BotManager) userEvent(ctx context.Context, chatID int64, id int) (*db.Event, error) {
+	status := db.EventStatusPending
+	dbEvent, err := bm.store.OneEvent(ctx, &db.EventSearch{UserEventID: &id, UserTgID: &chatID, StatusID: &status})
 	if err != nil {
 		return nil, err
 	}
 
 	if dbEvent == nil {
-		return nil, nil
+		return nil, ErrEventNotFound
 	}
 
-	return &model.Event{
-		ID:         dbEvent.ID,
+	return dbEvent, nil
+}
+
+// Location returns time zone of chat or default one if it has not been set.
+func (bm BotManager) Location(ctx context.Context, chatID int64) *time.Location {
+	user, err := bm.store.UserByID(ctx, chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки настроек пользователя: %v", err)
+		return bm.location
+	}
+
+	if user == nil {
+		return bm.location
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		log.Printf("Ошибка загрузки часового пояса %q: %v", user.Timezone, err)
+		return bm.location
+	}
+
+	return loc
+}
+
+// SetTimezone validates IANA time zone name and stores it in chat settings on behalf of user.
+// Time zone of group chat is shared by its members, so only admins may change it.
+func (bm BotManager) SetTimezone(ctx context.Context, chatID, userID int64, name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" || strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("invalid_timezone")
+	}
+
+	if err := bm.checkAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := bm.store.UserByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		user = &db.User{ID: chatID}
+	}
+	user.Timezone = loc.String()
+	if err := bm.store.SaveUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return loc, nil
+}
+
+func newEvent(dbEvent db.Event, loc *time.Location) model.Event {
+	event := model.Event{
+		ID:         dbEvent.UserEventID,
 		OriginalID: dbEvent.ID,
 		ChatID:     dbEvent.UserTgID,
 		Text:       dbEvent.Message,
-		DateTime:   dbEvent.SendAt,
-	}, nil
+		DateTime:   dbEvent.SendAt.In(loc),
+		StatusID:   dbEvent.StatusID,
+		Tags:       dbEvent.Tags,
+		Mentions:   dbEvent.Mentions,
+	}
+	if dbEvent.CreatorTgID != nil {
+		event.CreatorID = *dbEvent.CreatorTgID
+	}
+	if dbEvent.SentAt != nil {
+		sentAt := dbEvent.SentAt.In(loc)
+		event.SentAt = &sentAt
+	}
+	if dbEvent.Recurrence != nil {
+		event.Recurrence = *dbEvent.Recurrence
+	}
+
+	return event
 }
@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// newTestManager returns BotManager on memory store which does not talk to Telegram.
+func newTestManager() (*BotManager, *storage.Memory) {
+	store := storage.NewMemory()
+	return NewBotManager(nil, nil, store, clock.NewFake(testNow), time.UTC), store
+}
+
+func TestForeignChatCannotTouchEvent(t *testing.T) {
+	const owner, stranger = int64(1), int64(2)
+	ctx := context.Background()
+	bm, store := newTestManager()
+
+	// owner's events get per-chat IDs 1 and 2, stranger's one gets 1
+	for _, args := range []string{"2030-01-02 11:00 первое", "2030-01-02 12:00 встреча"} {
+		if _, err := bm.AddEvent(ctx, owner, owner, args); err != nil {
+			t.Fatalf("AddEvent: %v", err)
+		}
+	}
+	if _, err := bm.AddEvent(ctx, stranger, stranger, "2030-01-03 12:00 своё"); err != nil {
+		t.Fatalf("AddEvent: %v", err)
+	}
+
+	event, err := bm.GetEventByID(ctx, owner, 2)
+	if err != nil {
+		t.Fatalf("GetEventByID: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"get", func() error {
+			_, err := bm.GetEventByID(ctx, stranger, event.ID)
+			return err
+		}},
+		{"delete", func() error {
+			_, err := bm.DeleteEventByID(ctx, stranger, stranger, event.ID)
+			return err
+		}},
+		{"edit text", func() error {
+			_, err := bm.UpdateEventText(ctx, stranger, stranger, event.ID, "чужое")
+			return err
+		}},
+		{"edit time", func() error {
+			_, err := bm.UpdateEventTime(ctx, stranger, stranger, event.ID, "2030-02-01", "09:00")
+			return err
+		}},
+		{"postpone", func() error {
+			_, err := bm.PostponeEvent(ctx, stranger, stranger, event.ID)
+			return err
+		}},
+		{"snooze", func() error {
+			_, err := bm.SnoozeEvent(ctx, stranger, stranger, event.ID, "1h")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, ErrEventNotFound) {
+				t.Fatalf("got error %v, want ErrEventNotFound", err)
+			}
+
+			got, err := store.OneEvent(ctx, &db.EventSearch{ID: &event.OriginalID})
+			if err != nil || got == nil {
+				t.Fatalf("OneEvent: %v, %v", got, err)
+			}
+			if got.Message != "встреча" || !got.SendAt.Equal(event.DateTime) || got.StatusID != db.EventStatusPending {
+				t.Fatalf("event changed: %q %s status %d", got.Message, got.SendAt, got.StatusID)
+			}
+		})
+	}
+}
+
+func TestGroupEventAccess(t *testing.T) {
+	const group, author, member, admin = int64(-100), int64(1), int64(2), int64(3)
+	ctx := context.Background()
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+	api.SetMember(group, admin, models.ChatMemberTypeAdministrator)
+
+	b, err := bot.New(telegramtest.Token, bot.WithServerURL(api.URL()), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm := NewBotManager(b, nil, storage.NewMemory(), clock.NewFake(testNow), time.UTC)
+
+	event, err := bm.AddEvent(ctx, group, author, "2030-01-02 11:00 планёрка")
+	if err != nil {
+		t.Fatalf("AddEvent: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want error
+		fn   func() error
+	}{
+		{"member edits text", ErrForbidden, func() error {
+			_, err := bm.UpdateEventText(ctx, group, member, event.ID, "чужое")
+			return err
+		}},
+		{"member edits time", ErrForbidden, func() error {
+			_, err := bm.UpdateEventTime(ctx, group, member, event.ID, "2030-02-01", "09:00")
+			return err
+		}},
+		{"member postpones", ErrForbidden, func() error {
+			_, err := bm.PostponeEvent(ctx, group, member, event.ID)
+			return err
+		}},
+		{"member snoozes", ErrForbidden, func() error {
+			_, err := bm.SnoozeEvent(ctx, group, member, event.ID, Snooze1Hour)
+			return err
+		}},
+		{"member deletes", ErrForbidden, func() error {
+			_, err := bm.DeleteEventByID(ctx, group, member, event.ID)
+			return err
+		}},
+		{"author edits text", nil, func() error {
+			_, err := bm.UpdateEventText(ctx, group, author, event.ID, "планёрка в 301")
+			return err
+		}},
+		{"admin postpones", nil, func() error {
+			_, err := bm.PostponeEvent(ctx, group, admin, event.ID)
+			return err
+		}},
+		{"admin snoozes", nil, func() error {
+			_, err := bm.SnoozeEvent(ctx, group, admin, event.ID, Snooze1Hour)
+			return err
+		}},
+		{"member sets time zone", ErrForbidden, func() error {
+			_, err := bm.SetTimezone(ctx, group, member, "Europe/Berlin")
+			return err
+		}},
+		{"admin sets time zone", nil, func() error {
+			_, err := bm.SetTimezone(ctx, group, admin, "Europe/Berlin")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOccurrenceKeepsTimeOfDay(t *testing.T) {
+	const chat = int64(1)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2030, 3, 8, 12, 0, 0, 0, time.UTC))
+	bm := NewBotManager(nil, nil, storage.NewMemory(), clk, time.UTC)
+
+	if _, err := bm.SetTimezone(ctx, chat, chat, "America/New_York"); err != nil {
+		t.Fatalf("SetTimezone: %v", err)
+	}
+	event, err := bm.AddRecurringEvent(ctx, chat, chat, []string{"day", "02:30", "таблетки"})
+	if err != nil {
+		t.Fatalf("AddRecurringEvent: %v", err)
+	}
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const layout = "2006-01-02 15:04 MST"
+	got := []string{event.DateTime.In(ny).Format(layout)}
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			// later occurrences stay in time zone of the rule
+			if _, err := bm.SetTimezone(ctx, chat, chat, "Europe/Moscow"); err != nil {
+				t.Fatalf("SetTimezone: %v", err)
+			}
+		}
+
+		clk.Set(event.DateTime.Add(time.Minute))
+		if event, err = bm.NextOccurrence(ctx, chat, event.ID); err != nil {
+			t.Fatalf("NextOccurrence: %v", err)
+		}
+		got = append(got, event.DateTime.In(ny).Format(layout))
+	}
+
+	want := []string{
+		"2030-03-09 02:30 EST",
+		// 02:30 does not exist on the day clocks go forward
+		"2030-03-10 03:30 EDT",
+		"2030-03-11 02:30 EDT",
+		"2030-03-12 02:30 EDT",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("occurrences = %q, want %q", got, want)
+			break
+		}
+	}
+}
+
+// Snoozed delivered event is pending again with its advance notifications moved to the new time.
+func TestSnoozeDeliveredEvent(t *testing.T) {
+	const chat = int64(1)
+	ctx := context.Background()
+	bm, store := newTestManager()
+
+	event, err := bm.AddEvent(ctx, chat, chat, "2030-01-01 11:00 -1h,-15m встреча")
+	if err != nil {
+		t.Fatalf("AddEvent: %v", err)
+	}
+
+	// event and its notifications are delivered
+	dbEvent, err := store.OneEvent(ctx, &db.EventSearch{ID: &event.OriginalID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbEvent.StatusID, dbEvent.SentAt = db.EventStatusSent, &dbEvent.SendAt
+	if _, err := store.UpdateEvent(ctx, dbEvent); err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := store.NotificationsByFilters(ctx, &db.NotificationSearch{EventID: &event.OriginalID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range notifications {
+		n.StatusID, n.SentAt = db.EventStatusSent, &n.SendAt
+		if _, err := store.UpdateNotification(ctx, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snoozed, err := bm.SnoozeEvent(ctx, chat, chat, event.ID, SnoozeMorning)
+	if err != nil {
+		t.Fatalf("SnoozeEvent: %v", err)
+	}
+
+	until := time.Date(2030, 1, 2, morningHour, 0, 0, 0, time.UTC)
+	if !snoozed.DateTime.Equal(until) || snoozed.StatusID != db.EventStatusPending || snoozed.SentAt != nil {
+		t.Errorf("snoozed event at %s, status %d, sent at %v, want pending at %s", snoozed.DateTime, snoozed.StatusID, snoozed.SentAt, until)
+	}
+
+	want := map[time.Duration]time.Time{time.Hour: until.Add(-time.Hour), 15 * time.Minute: until.Add(-15 * time.Minute)}
+	if len(snoozed.Notifications) != len(want) {
+		t.Fatalf("notifications = %+v, want %d", snoozed.Notifications, len(want))
+	}
+	for _, n := range snoozed.Notifications {
+		if n.StatusID != db.EventStatusPending || !n.DateTime.Equal(want[n.Offset]) {
+			t.Errorf("notification %s before is at %s with status %d, want pending at %s", n.Offset, n.DateTime, n.StatusID, want[n.Offset])
+		}
+	}
+}
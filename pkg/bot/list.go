@@ -0,0 +1,356 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+)
+
+// callback data of /list keyboard: "list:<action>:<id>:<page>:<filter>", id is 0 for page navigation
+const (
+	CallbackList = "list:"
+
+	ListActionPage   = "page"
+	ListActionDelete = "del"
+	ListActionEdit   = "edit"
+	ListActionSnooze = "snooze"
+)
+
+// list filter specs, besides them spec may be a date range "2025-09-01..2025-09-07" or a single date
+const (
+	FilterToday    = "today"
+	FilterTomorrow = "tomorrow"
+	FilterWeek     = "week"
+	// FilterFind is a prefix of text search spec: "?врач"
+	FilterFind = "?"
+)
+
+const (
+	// callbackDataLimit is max length of callback data in bytes allowed by Telegram.
+	callbackDataLimit = 64
+	// listPageSize is number of events on one /list page.
+	listPageSize = 5
+	// listTextLimit is max length of event text in /list, so that a page usually fits into one message.
+	listTextLimit = 500
+	// listTextMin is length event text is cut to when a page does not fit into one message, tags are cut after it.
+	listTextMin = 50
+	// messageLimit is max length of message text in UTF-16 code units allowed by Telegram.
+	messageLimit = 4096
+	// listSnooze is how far event is postponed by snooze button of /list.
+	listSnooze = time.Hour
+	// listSpecLimit is max length of filter spec in bytes, so that callback data of the longest button
+	// with max event ID and page number fits into callbackDataLimit.
+	listSpecLimit = callbackDataLimit - len(CallbackList+ListActionSnooze) - len(":2147483647:999999:")
+)
+
+// ErrFilterTooLong is returned when filter spec does not fit into callback data of /list buttons.
+var ErrFilterTooLong = errors.New("filter_too_long")
+
+// ListFilter selects events shown by /list and its variants.
+type ListFilter struct {
+	// Spec is filter as written in command and callback data.
+	Spec  string
+	Title string
+	Text  string
+	// Tags selects events having any of tags.
+	Tags []string
+	// From and To limit event time, To is exclusive.
+	From, To *time.Time
+}
+
+// ParseListFilter parses filter spec relative to now in user time zone.
+func ParseListFilter(spec string, now time.Time) (ListFilter, error) {
+	f := ListFilter{Spec: spec, Title: "📅 Список событий (от ближайших)"}
+	if len(spec) > listSpecLimit {
+		return f, ErrFilterTooLong
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	period := func(title string, from, to time.Time) {
+		f.Title, f.From, f.To = title, &from, &to
+	}
+
+	switch {
+	case spec == "":
+	case spec == FilterToday:
+		period("📅 События на сегодня", today, today.AddDate(0, 0, 1))
+	case spec == FilterTomorrow:
+		period("📅 События на завтра", today.AddDate(0, 0, 1), today.AddDate(0, 0, 2))
+	case spec == FilterWeek:
+		period("📅 События на неделю", today, today.AddDate(0, 0, 7))
+	case strings.HasPrefix(spec, FilterFind):
+		f.Text = strings.TrimPrefix(spec, FilterFind)
+		f.Title = fmt.Sprintf("🔍 Поиск «%s»", f.Text)
+	case strings.Contains(spec, tagPrefix):
+		// "#work #home" or "2025-09-01..2025-09-07 #work"
+		var rest []string
+		for _, word := range strings.Fields(spec) {
+			if isTag(word) {
+				f.Tags = appendTag(f.Tags, word)
+			} else {
+				rest = append(rest, word)
+			}
+		}
+		if len(f.Tags) == 0 || len(rest) > 1 {
+			return f, fmt.Errorf("invalid_filter")
+		}
+
+		period, err := ParseListFilter(strings.Join(rest, ""), now)
+		if err != nil {
+			return f, err
+		}
+		f.Title, f.From, f.To = period.Title+tagsSuffix(f.Tags), period.From, period.To
+	default:
+		fromPart, toPart, isRange := strings.Cut(spec, "..")
+		if !isRange {
+			toPart = fromPart
+		}
+
+		from, err := time.ParseInLocation("2006-01-02", fromPart, now.Location())
+		if err != nil {
+			return f, fmt.Errorf("invalid_filter")
+		}
+		to, err := time.ParseInLocation("2006-01-02", toPart, now.Location())
+		if err != nil || to.Before(from) {
+			return f, fmt.Errorf("invalid_filter")
+		}
+
+		title := fmt.Sprintf("📅 События с %s по %s", fromPart, toPart)
+		if !isRange {
+			title = "📅 События на " + fromPart
+		}
+		period(title, from, to.AddDate(0, 0, 1))
+	}
+
+	return f, nil
+}
+
+func (f ListFilter) search() *db.EventSearch {
+	search := &db.EventSearch{SendAtFrom: f.From, SendAtTo: f.To}
+	if f.Text != "" {
+		search.MessageILike = &f.Text
+	}
+
+	switch len(f.Tags) {
+	case 0:
+	case 1:
+		search.Tag = &f.Tags[0]
+	default:
+		search.TagsAny = f.Tags
+	}
+
+	return search
+}
+
+// ListPage returns text and keyboard of events page matching filter spec, pages start from 1.
+// Page is clamped to the last one, e.g. after deleting the only event of the last page.
+func (bm BotManager) ListPage(ctx context.Context, chatID int64, spec string, page int) (string, *models.InlineKeyboardMarkup, error) {
+	filter, err := ParseListFilter(spec, bm.Now().In(bm.Location(ctx, chatID)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	events, total, err := bm.GetUserEvents(ctx, chatID, filter, db.NewPager(page, listPageSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	pages := (total + listPageSize - 1) / listPageSize
+	if total == 0 && spec == "" {
+		return "🔍 Нет событий", nil, nil
+	} else if total == 0 {
+		return filter.Title + "\n\n🔍 Ничего не найдено", nil, nil
+	} else if page > pages {
+		return bm.ListPage(ctx, chatID, spec, pages)
+	}
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("%s, страница %d из %d:\n\n", filter.Title, page, pages))
+	lineLimit := (messageLimit - messageLength(msg.String())) / len(events)
+	for i, e := range events {
+		msg.WriteString(listLine((page-1)*listPageSize+i+1, e, lineLimit))
+	}
+
+	return msg.String(), listKeyboard(events, spec, page, pages), nil
+}
+
+// listLine returns line of event in /list cut to limit: event text is cut first, then tags.
+func listLine(n int, e model.Event, limit int) string {
+	head := fmt.Sprintf("%d. ", n)
+	text := truncateText(e.Text, listTextLimit)
+	details := fmt.Sprintf(" — %s%s%s",
+		e.DateTime.Format("2006-01-02 15:04"),
+		recurrenceSuffix(e.Recurrence),
+		notificationsSuffix(e.Notifications),
+	)
+	tags := tagsSuffix(e.Tags)
+	id := fmt.Sprintf(" (ID: %d)\n", e.ID)
+
+	fixed := messageLength(head + details + id)
+	if over := fixed + messageLength(text+tags) - limit; over > 0 {
+		text = truncateLength(text, max(messageLength(text)-over, listTextMin))
+	}
+	if over := fixed + messageLength(text+tags) - limit; over > 0 {
+		tags = truncateLength(tags, messageLength(tags)-over)
+	}
+
+	return head + text + details + tags + id
+}
+
+// listKeyboard returns per-event action buttons and page navigation.
+func listKeyboard(events []model.Event, spec string, page, pages int) *models.InlineKeyboardMarkup {
+	button := func(text, action string, id, page int) models.InlineKeyboardButton {
+		return listButton(text, action, id, page, spec)
+	}
+
+	var rows [][]models.InlineKeyboardButton
+	for _, e := range events {
+		id := strconv.Itoa(e.ID)
+		rows = append(rows, []models.InlineKeyboardButton{
+			button("🗑 "+id, ListActionDelete, e.ID, page),
+			button("✏️ "+id, ListActionEdit, e.ID, page),
+			button("⏰ +1ч "+id, ListActionSnooze, e.ID, page),
+		})
+	}
+
+	var nav []models.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, button("‹ Назад", ListActionPage, 0, page-1))
+	}
+	if page < pages {
+		nav = append(nav, button("Вперёд ›", ListActionPage, 0, page+1))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// listButton returns button with callback data, spec is limited by ParseListFilter to fit callback data limit.
+func listButton(text, action string, id, page int, spec string) models.InlineKeyboardButton {
+	return models.InlineKeyboardButton{Text: text, CallbackData: fmt.Sprintf("%s%s:%d:%d:%s", CallbackList, action, id, page, spec)}
+}
+
+// ParseListData parses "list:<action>:<id>:<page>:<filter>" callback data.
+func ParseListData(data string) (action string, id, page int, spec string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(data, CallbackList), ":", 4)
+	if len(parts) < 3 {
+		return "", 0, 0, "", fmt.Errorf("invalid list data %q", data)
+	}
+
+	if id, err = strconv.Atoi(parts[1]); err != nil {
+		return "", 0, 0, "", err
+	}
+	if page, err = strconv.Atoi(parts[2]); err != nil {
+		return "", 0, 0, "", err
+	}
+	if len(parts) == 4 {
+		spec = parts[3]
+	}
+
+	return parts[0], id, page, spec, nil
+}
+
+// PostponeEvent moves upcoming event by listSnooze on behalf of user and returns changed events.
+// For recurring event only the nearest occurrence is postponed: a one-shot copy is created
+// and the event itself is moved to the next occurrence.
+func (bm BotManager) PostponeEvent(ctx context.Context, chatID, userID int64, id int) ([]model.Event, error) {
+	dbEvent, err := bm.userEvent(ctx, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bm.checkAccess(ctx, dbEvent, userID); err != nil {
+		return nil, err
+	}
+
+	loc := bm.Location(ctx, chatID)
+	sendAt := dbEvent.SendAt.Add(listSnooze)
+
+	if dbEvent.Recurrence == nil {
+		dbEvent.SendAt = sendAt
+		event, err := bm.updateEvent(ctx, dbEvent, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Notifications, err = bm.rescheduleNotifications(ctx, dbEvent, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		return []model.Event{*event}, nil
+	}
+
+	event, err := bm.addEvent(ctx, &db.Event{
+		UserTgID:    chatID,
+		Message:     dbEvent.Message,
+		Tags:        dbEvent.Tags,
+		Mentions:    dbEvent.Mentions,
+		CreatorTgID: dbEvent.CreatorTgID,
+		SendAt:      sendAt,
+		StatusID:    db.EventStatusPending,
+	}, nil, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// upcoming occurrence is in the future, so the event moves to the one after it
+	next, err := bm.NextOccurrence(ctx, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return []model.Event{*event, *next}, nil
+}
+
+// truncateText cuts text to limit runes.
+func truncateText(text string, limit int) string {
+	r := []rune(text)
+	if len(r) <= limit {
+		return text
+	}
+
+	return string(r[:limit]) + "…"
+}
+
+// messageLength returns length of text as Telegram counts it, in UTF-16 code units.
+func messageLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
+
+// truncateLength cuts text to limit UTF-16 code units including the ellipsis.
+func truncateLength(text string, limit int) string {
+	if messageLength(text) <= limit {
+		return text
+	}
+
+	n := 0
+	for i, r := range text {
+		if n+utf16.RuneLen(r) > limit-1 {
+			return text[:i] + "…"
+		}
+		n += utf16.RuneLen(r)
+	}
+
+	return text
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestListFilterFitsCallbackData(t *testing.T) {
+	spec := FilterFind + strings.Repeat("я", (listSpecLimit-len(FilterFind))/len("я"))
+	if _, err := ParseListFilter(spec, testNow); err != nil {
+		t.Fatalf("ParseListFilter(%q): %v", spec, err)
+	}
+
+	button := listButton("⏰", ListActionSnooze, 2147483647, 999999, spec)
+	if len(button.CallbackData) > callbackDataLimit {
+		t.Errorf("callback data is %d bytes, limit is %d", len(button.CallbackData), callbackDataLimit)
+	}
+	if _, _, _, got, err := ParseListData(button.CallbackData); err != nil || got != spec {
+		t.Errorf("ParseListData = %q, %v, want %q", got, err, spec)
+	}
+
+	if _, err := ParseListFilter(spec+"я", testNow); !errors.Is(err, ErrFilterTooLong) {
+		t.Errorf("ParseListFilter of long spec: %v, want ErrFilterTooLong", err)
+	}
+}
+
+func TestListPageFitsMessage(t *testing.T) {
+	const user = int64(1)
+	ctx := context.Background()
+	bm, _ := newTestManager()
+
+	// a full page of events with the longest text of emoji, which take two UTF-16 code units, and many tags
+	var tags []string
+	for i := 0; i < 200; i++ {
+		tags = append(tags, fmt.Sprintf("#тег%d", i))
+	}
+	text := strings.Join(tags, " ") + " " + strings.Repeat("😀", listTextLimit)
+	for i := 0; i < listPageSize; i++ {
+		if _, err := bm.AddEvent(ctx, user, user, fmt.Sprintf("2030-01-02 1%d:00 %s", i, text)); err != nil {
+			t.Fatalf("AddEvent: %v", err)
+		}
+	}
+
+	page, _, err := bm.ListPage(ctx, user, "", 1)
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+
+	if n := messageLength(page); n > messageLimit {
+		t.Errorf("page is %d UTF-16 code units, limit is %d", n, messageLimit)
+	}
+	for i := 1; i <= listPageSize; i++ {
+		if want := fmt.Sprintf("(ID: %d)\n", i); !strings.Contains(page, want) {
+			t.Errorf("page has no %q", want)
+		}
+	}
+	if !strings.Contains(page, "😀😀… — 2030-01-02 10:00 🏷 #тег0 #тег1") {
+		t.Errorf("page does not keep beginning of text and tags:\n%s", page)
+	}
+}
+
+func TestTruncateLength(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "ab…"},
+		{"😀😀😀", 6, "😀😀😀"},
+		{"😀😀😀", 5, "😀😀…"},
+		{"😀😀😀", 4, "😀…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncateLength(tt.text, tt.limit); got != tt.want {
+			t.Errorf("truncateLength(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
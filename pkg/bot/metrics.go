@@ -0,0 +1,23 @@
+package bot
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	eventsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "bot_events_created_total",
+		Help: "Number of events created by users.",
+	})
+	eventsDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "bot_events_deleted_total",
+		Help: "Number of events deleted by users.",
+	})
+	deliveryLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_delivery_latency_seconds",
+		Help:    "Time from scheduled send time to actual delivery of reminder.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"kind"})
+)
+
+func init() {
+	prometheus.MustRegister(eventsCreated, eventsDeleted, deliveryLatency)
+}
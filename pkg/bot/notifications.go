@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+)
+
+var offsetUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// splitOffsets cuts leading advance notification offsets like "-1d,-1h" from event text.
+// Text is returned unchanged if it does not start with offsets.
+func splitOffsets(text string) ([]time.Duration, string) {
+	first, rest, ok := strings.Cut(text, " ")
+	if !ok || !strings.HasPrefix(first, "-") {
+		return nil, text
+	}
+
+	offsets, err := parseOffsets(first)
+	if err != nil {
+		return nil, text
+	}
+
+	return offsets, strings.TrimSpace(rest)
+}
+
+// parseOffsets parses comma separated offsets: -30m, -2h, -1d, -1w.
+func parseOffsets(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		if len(part) < 3 || part[0] != '-' {
+			return nil, fmt.Errorf("invalid offset %q", part)
+		}
+
+		unit, ok := offsetUnits[part[len(part)-1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid offset unit %q", part)
+		}
+
+		n, err := strconv.Atoi(part[1 : len(part)-1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid offset value %q", part)
+		}
+
+		offsets = append(offsets, time.Duration(n)*unit)
+	}
+
+	return offsets, nil
+}
+
+// formatOffset returns offset in short human-readable form, e.g. "1 д", "2 ч", "30 мин".
+func formatOffset(d time.Duration) string {
+	switch {
+	case d%(7*24*time.Hour) == 0:
+		return fmt.Sprintf("%d нед", d/(7*24*time.Hour))
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d д", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d ч", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d мин", d/time.Minute)
+	}
+}
+
+// notificationsSuffix returns " ⏰ за 1 д, 1 ч" for pending advance notifications.
+func notificationsSuffix(notifications []model.Notification) string {
+	var offsets []string
+	for _, n := range notifications {
+		if n.StatusID == db.EventStatusPending {
+			offsets = append(offsets, formatOffset(n.Offset))
+		}
+	}
+
+	if len(offsets) == 0 {
+		return ""
+	}
+
+	return " ⏰ за " + strings.Join(offsets, ", ")
+}
+
+func newNotification(n db.Notification, loc *time.Location) model.Notification {
+	return model.Notification{
+		ID:       n.ID,
+		EventID:  n.EventID,
+		Offset:   time.Duration(n.OffsetMinutes) * time.Minute,
+		DateTime: n.SendAt.In(loc),
+		StatusID: n.StatusID,
+	}
+}
+
+// addNotifications stores advance notifications of event. Offsets already in the past are skipped.
+func (bm BotManager) addNotifications(ctx context.Context, dbEvent *db.Event, offsets []time.Duration, loc *time.Location) ([]model.Notification, error) {
+	var notifications []model.Notification
+	for _, offset := range offsets {
+		sendAt := dbEvent.SendAt.Add(-offset)
+		if sendAt.Before(bm.Now()) {
+			continue
+		}
+
+		n, err := bm.store.AddNotification(ctx, &db.Notification{
+			EventID:       dbEvent.ID,
+			OffsetMinutes: int(offset / time.Minute),
+			SendAt:        sendAt,
+			StatusID:      db.EventStatusPending,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, newNotification(*n, loc))
+	}
+
+	return notifications, nil
+}
+
+// eventNotifications returns notifications of events grouped by event ID.
+func (bm BotManager) eventNotifications(ctx context.Context, eventIDs []int, loc *time.Location) (map[int][]model.Notification, error) {
+	result := make(map[int][]model.Notification)
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	list, err := bm.store.NotificationsByFilters(ctx, &db.NotificationSearch{EventIDs: eventIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range list {
+		result[n.EventID] = append(result[n.EventID], newNotification(n, loc))
+	}
+
+	return result, nil
+}
+
+// rescheduleNotifications moves notifications of event after its SendAt has changed.
+// Notifications which fall into the past are cancelled.
+func (bm BotManager) rescheduleNotifications(ctx context.Context, dbEvent *db.Event, loc *time.Location) ([]model.Notification, error) {
+	list, err := bm.store.NotificationsByFilters(ctx, &db.NotificationSearch{EventID: &dbEvent.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]model.Notification, 0, len(list))
+	for _, n := range list {
+		n.SendAt = dbEvent.SendAt.Add(-time.Duration(n.OffsetMinutes) * time.Minute)
+		n.SentAt = nil
+		n.StatusID = db.EventStatusPending
+		if n.SendAt.Before(bm.Now()) {
+			n.StatusID = db.EventStatusCancelled
+		}
+
+		if _, err := bm.store.UpdateNotification(ctx, &n); err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, newNotification(n, loc))
+	}
+
+	return notifications, nil
+}
+
+// ClaimNotification locks pending notification row for delivery and returns it with its event.
+// It returns ErrEventNotFound if notification or event is not pending, or row is locked by another transaction.
+func (bm BotManager) ClaimNotification(ctx context.Context, id int) (*model.Notification, *model.Event, error) {
+	status := db.EventStatusPending
+	n, err := bm.store.ClaimNotification(ctx, &db.NotificationSearch{ID: &id, StatusID: &status})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n == nil || n.Event == nil || n.Event.StatusID != db.EventStatusPending {
+		return nil, nil, ErrEventNotFound
+	}
+
+	loc := bm.Location(ctx, n.Event.UserTgID)
+	notification := newNotification(*n, loc)
+	event := newEvent(*n.Event, loc)
+	return &notification, &event, nil
+}
+
+func (bm BotManager) setNotificationStatus(ctx context.Context, id, statusID int, sentAt *time.Time) error {
+	n, err := bm.store.NotificationByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if n == nil {
+		return ErrEventNotFound
+	}
+
+	n.StatusID = statusID
+	n.SentAt = sentAt
+	_, err = bm.store.UpdateNotification(ctx, n)
+	return err
+}
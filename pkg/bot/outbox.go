@@ -0,0 +1,342 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+	"github.com/kanef1/event-reminder-bot/pkg/sender"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+)
+
+const (
+	// outboxMaxAttempts is a number of delivery attempts after which message is left in failed (dead-letter) status.
+	outboxMaxAttempts = 5
+	// outboxBaseBackoff is a delay before the second attempt, it doubles with every next one.
+	outboxBaseBackoff = 30 * time.Second
+	// outboxMaxBackoff limits delay between attempts.
+	outboxMaxBackoff = time.Hour
+	// outboxSendTimeout limits one attempt including wait for rate limiter.
+	outboxSendTimeout = time.Minute
+	// outboxLease is how long message stays in sending status before another attempt may claim it,
+	// it is longer than outboxSendTimeout so that the attempt is over by then.
+	outboxLease = 2 * outboxSendTimeout
+)
+
+func reminderText(e model.Event) string {
+	return "🔔 Напоминание: " + e.Text + mentionsLine(e.Mentions)
+}
+
+// lateReminderText is a text of reminder that was missed while the bot was down, with its original time.
+func lateReminderText(e model.Event) string {
+	return fmt.Sprintf("🔔 Напоминание (с опозданием, время события %s): %s%s",
+		e.DateTime.Format("2006-01-02 15:04"), e.Text, mentionsLine(e.Mentions))
+}
+
+func notificationText(n model.Notification, e model.Event) string {
+	return fmt.Sprintf("⏰ Напоминание за %s: %s (%s)%s",
+		formatOffset(n.Offset), e.Text, e.DateTime.Format("2006-01-02 15:04"), mentionsLine(e.Mentions))
+}
+
+// EnqueueReminder puts reminder of claimed event to outbox and returns ID of outbox message.
+// One-shot event moves to sending status until delivery, recurring one stays pending for NextOccurrence.
+func (bm BotManager) EnqueueReminder(ctx context.Context, e model.Event) (int, error) {
+	return bm.enqueueEvent(ctx, e, reminderText(e))
+}
+
+// EnqueueLateReminder is EnqueueReminder for reminder missed while the bot was down.
+func (bm BotManager) EnqueueLateReminder(ctx context.Context, e model.Event) (int, error) {
+	return bm.enqueueEvent(ctx, e, lateReminderText(e))
+}
+
+// CatchUp is a policy for overdue reminders, e.g. missed while the bot was down.
+type CatchUp struct {
+	// Window is a grace period: reminders overdue less than Window are delivered late.
+	Window time.Duration
+	// Drop deletes reminders older than Window instead of archiving them as failed.
+	Drop bool
+}
+
+// outcomes of CatchUpEvent
+const (
+	CatchUpLate     = "late"
+	CatchUpArchived = "archived"
+	CatchUpDropped  = "dropped"
+	CatchUpSkipped  = "skipped"
+)
+
+// CatchUpEvent applies catch-up policy to claimed event overdue by given duration.
+// It returns outcome and ID of outbox message if late reminder was put to outbox.
+// Occurrence of recurring event older than window is skipped, caller moves it by NextOccurrence.
+func (bm BotManager) CatchUpEvent(ctx context.Context, e model.Event, overdue time.Duration, policy CatchUp) (string, int, error) {
+	switch {
+	case overdue <= policy.Window:
+		id, err := bm.EnqueueLateReminder(ctx, e)
+		return CatchUpLate, id, err
+	case e.Recurrence != "":
+		return CatchUpSkipped, 0, nil
+	case policy.Drop:
+		if _, err := bm.store.DeleteEvent(ctx, e.OriginalID); err != nil {
+			return "", 0, err
+		}
+		return CatchUpDropped, 0, nil
+	default:
+		if err := bm.MarkEventFailed(ctx, e.ChatID, e.ID); err != nil {
+			return "", 0, err
+		}
+		return CatchUpArchived, 0, nil
+	}
+}
+
+func (bm BotManager) enqueueEvent(ctx context.Context, e model.Event, text string) (int, error) {
+	dbEvent, err := bm.userEvent(ctx, e.ChatID, e.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	outbox, err := bm.store.AddOutbox(ctx, &db.Outbox{
+		EventID:       dbEvent.ID,
+		UserTgID:      dbEvent.UserTgID,
+		Message:       text,
+		SendAt:        dbEvent.SendAt,
+		StatusID:      db.EventStatusPending,
+		NextAttemptAt: bm.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if dbEvent.Recurrence == nil {
+		dbEvent.StatusID = db.EventStatusSending
+		if _, err := bm.store.UpdateEvent(ctx, dbEvent); err != nil {
+			return 0, err
+		}
+	}
+
+	return outbox.ID, nil
+}
+
+// EnqueueNotification puts advance notification claimed by ClaimNotification to outbox and moves it to sending status.
+func (bm BotManager) EnqueueNotification(ctx context.Context, n model.Notification, e model.Event) (int, error) {
+	outbox, err := bm.store.AddOutbox(ctx, &db.Outbox{
+		EventID:        e.OriginalID,
+		NotificationID: &n.ID,
+		UserTgID:       e.ChatID,
+		Message:        notificationText(n, e),
+		SendAt:         n.DateTime,
+		StatusID:       db.EventStatusPending,
+		NextAttemptAt:  bm.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return outbox.ID, bm.setNotificationStatus(ctx, n.ID, db.EventStatusSending, nil)
+}
+
+// SendOutbox claims pending outbox message, sends it and records the result. It returns updated message:
+//   - sent, its event or notification is marked delivered;
+//   - pending with Attempts and NextAttemptAt of the next attempt, delay grows exponentially and is not
+//     less than retry_after of Telegram;
+//   - failed (dead letter) after outboxMaxAttempts or on error which is not fixed by retry, e.g. bot is blocked,
+//     its one-shot event or notification is marked failed;
+//   - cancelled if its event was cancelled or rescheduled meanwhile.
+//
+// Claim and result are stored in two short transactions and message is sent between them, so store is not
+// locked while sender waits for rate limiter or Telegram. SendOutbox must not be called in transaction.
+// It returns ErrEventNotFound if message is not pending or is claimed by another instance.
+func (bm BotManager) SendOutbox(ctx context.Context, id int) (*db.Outbox, error) {
+	var outbox *db.Outbox
+	err := bm.store.RunInTransaction(ctx, func(s storage.EventStore) (err error) {
+		outbox, err = bm.WithStore(s).claimOutbox(ctx, id)
+		return err
+	})
+	if err != nil || outbox.StatusID != db.EventStatusSending {
+		return outbox, err
+	}
+
+	params := &bot.SendMessageParams{ChatID: outbox.UserTgID, Text: outbox.Message}
+	if outbox.NotificationID == nil {
+		params.ReplyMarkup = reminderKeyboard(outbox.Event.UserEventID)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+	_, sendErr := bm.sender.SendMessage(sendCtx, sender.PriorityReminder, params)
+	cancel()
+
+	// result of sent message is recorded on shutdown too, otherwise it would be sent again after lease
+	ctx = context.WithoutCancel(ctx)
+	err = bm.store.RunInTransaction(ctx, func(s storage.EventStore) (err error) {
+		outbox, err = bm.WithStore(s).recordOutbox(ctx, outbox, sendErr)
+		return err
+	})
+
+	return outbox, err
+}
+
+// claimOutbox moves pending message or message whose sender lost it, e.g. crashed, to sending status
+// for outboxLease and counts the attempt. Message is cancelled if it is not wanted any more.
+func (bm BotManager) claimOutbox(ctx context.Context, id int) (*db.Outbox, error) {
+	pending, sending, now := db.EventStatusPending, db.EventStatusSending, bm.Now()
+
+	outbox, err := bm.store.ClaimOutbox(ctx, &db.OutboxSearch{ID: &id, StatusID: &pending})
+	if err == nil && outbox == nil {
+		outbox, err = bm.store.ClaimOutbox(ctx, &db.OutboxSearch{ID: &id, StatusID: &sending, NextAttemptAtTo: &now})
+	}
+	if err != nil {
+		return nil, err
+	} else if outbox == nil {
+		return nil, ErrEventNotFound
+	}
+
+	active, err := bm.outboxActive(ctx, outbox)
+	if err != nil {
+		return nil, err
+	}
+
+	if active {
+		outbox.StatusID = db.EventStatusSending
+		outbox.Attempts++
+		outbox.NextAttemptAt = now.Add(outboxLease)
+	} else {
+		outbox.StatusID = db.EventStatusCancelled
+	}
+
+	_, err = bm.store.UpdateOutbox(ctx, outbox)
+	return outbox, err
+}
+
+// recordOutbox stores result of attempt claimed by claimOutbox. It returns ErrEventNotFound
+// if lease has expired and message was claimed again.
+func (bm BotManager) recordOutbox(ctx context.Context, claimed *db.Outbox, sendErr error) (*db.Outbox, error) {
+	sending := db.EventStatusSending
+	outbox, err := bm.store.ClaimOutbox(ctx, &db.OutboxSearch{ID: &claimed.ID, StatusID: &sending, Attempts: &claimed.Attempts})
+	if err != nil {
+		return nil, err
+	} else if outbox == nil {
+		return nil, ErrEventNotFound
+	}
+
+	now := bm.Now()
+	switch {
+	case sendErr == nil:
+		outbox.StatusID = db.EventStatusSent
+		outbox.SentAt = &now
+		outbox.LastError = nil
+		deliveryLatency.WithLabelValues(outboxKind(outbox)).Observe(now.Sub(outbox.SendAt).Seconds())
+	case permanentError(sendErr) || outbox.Attempts >= outboxMaxAttempts:
+		msg := sendErr.Error()
+		outbox.StatusID = db.EventStatusFailed
+		outbox.LastError = &msg
+	default:
+		msg := sendErr.Error()
+		outbox.StatusID = db.EventStatusPending
+		outbox.LastError = &msg
+		outbox.NextAttemptAt = now.Add(retryDelay(sendErr, outbox.Attempts))
+	}
+
+	if _, err := bm.store.UpdateOutbox(ctx, outbox); err != nil {
+		return nil, err
+	}
+
+	switch outbox.StatusID {
+	case db.EventStatusSent:
+		err = bm.markDelivered(ctx, outbox, now)
+	case db.EventStatusFailed:
+		err = bm.markUndelivered(ctx, outbox)
+	}
+
+	return outbox, err
+}
+
+// outboxActive reports whether message is still wanted: its event is not cancelled and it waits for this delivery.
+func (bm BotManager) outboxActive(ctx context.Context, outbox *db.Outbox) (bool, error) {
+	e := outbox.Event
+	if e == nil {
+		return false, nil
+	}
+
+	if outbox.NotificationID == nil {
+		if e.Recurrence != nil {
+			return e.StatusID == db.EventStatusPending, nil
+		}
+		return e.StatusID == db.EventStatusSending, nil
+	}
+
+	if e.StatusID != db.EventStatusPending {
+		return false, nil
+	}
+
+	n, err := bm.store.NotificationByID(ctx, *outbox.NotificationID)
+	if err != nil {
+		return false, err
+	}
+
+	return n != nil && n.StatusID == db.EventStatusSending, nil
+}
+
+// markDelivered stores delivery time of event or notification of sent message.
+// One-shot event moves to sent status, recurring one stays pending.
+func (bm BotManager) markDelivered(ctx context.Context, outbox *db.Outbox, sentAt time.Time) error {
+	if outbox.NotificationID != nil {
+		return bm.setNotificationStatus(ctx, *outbox.NotificationID, db.EventStatusSent, &sentAt)
+	}
+
+	event := outbox.Event
+	event.SentAt = &sentAt
+	if event.StatusID == db.EventStatusSending {
+		event.StatusID = db.EventStatusSent
+	}
+
+	_, err := bm.store.UpdateEvent(ctx, event)
+	return err
+}
+
+// markUndelivered moves one-shot event or notification of dead-letter message to failed status.
+func (bm BotManager) markUndelivered(ctx context.Context, outbox *db.Outbox) error {
+	if outbox.NotificationID != nil {
+		return bm.setNotificationStatus(ctx, *outbox.NotificationID, db.EventStatusFailed, nil)
+	}
+
+	event := outbox.Event
+	if event.StatusID != db.EventStatusSending {
+		return nil
+	}
+
+	event.StatusID = db.EventStatusFailed
+	_, err := bm.store.UpdateEvent(ctx, event)
+	return err
+}
+
+// outboxKind returns kind of message for metrics.
+func outboxKind(outbox *db.Outbox) string {
+	if outbox.NotificationID != nil {
+		return "notification"
+	}
+	return "reminder"
+}
+
+// permanentError reports whether send error is not fixed by retry: bot is blocked or kicked, chat not found etc.
+func permanentError(err error) bool {
+	return errors.Is(err, bot.ErrorForbidden) || errors.Is(err, bot.ErrorBadRequest)
+}
+
+// retryDelay returns delay before attempt after failed attempt number n: exponential backoff
+// limited by outboxMaxBackoff, but not less than retry_after of Telegram.
+func retryDelay(err error, n int) time.Duration {
+	delay := outboxMaxBackoff
+	if n <= 10 {
+		delay = min(outboxBaseBackoff<<(n-1), outboxMaxBackoff)
+	}
+
+	var tooMany *bot.TooManyRequestsError
+	if errors.As(err, &tooMany) {
+		delay = max(delay, time.Duration(tooMany.RetryAfter)*time.Second)
+	}
+
+	return delay
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/sender"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+// newOutboxManager returns BotManager sending to API at url and ID of outbox message of its due event.
+func newOutboxManager(t *testing.T, url string) (*BotManager, *storage.Memory, *clock.Fake, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	b, err := bot.New(telegramtest.Token, bot.WithServerURL(url), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, clk := storage.NewMemory(), clock.NewFake(testNow)
+	bm := NewBotManager(b, sender.New(b, clock.Real, sender.Limits{}), store, clk, time.UTC)
+
+	added, err := bm.AddEvent(ctx, 1, 1, "2030-01-01 10:01 встреча")
+	if err != nil {
+		t.Fatalf("AddEvent: %v", err)
+	}
+	clk.Advance(time.Minute)
+
+	event, err := bm.ClaimEvent(ctx, 1, added.ID)
+	if err != nil {
+		t.Fatalf("ClaimEvent: %v", err)
+	}
+	id, err := bm.EnqueueReminder(ctx, *event)
+	if err != nil {
+		t.Fatalf("EnqueueReminder: %v", err)
+	}
+
+	return bm, store, clk, id
+}
+
+func outboxByID(t *testing.T, store storage.EventStore, id int) db.Outbox {
+	t.Helper()
+
+	list, err := store.OutboxesByFilters(context.Background(), &db.OutboxSearch{ID: &id})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("OutboxesByFilters: %v, %v", list, err)
+	}
+
+	return list[0]
+}
+
+// TestSendOutboxOutsideTransaction checks that store is not locked while message is being sent.
+func TestSendOutboxOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			http.NotFound(w, r)
+			return
+		}
+
+		started <- struct{}{}
+		<-release
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`)
+	}))
+	t.Cleanup(api.Close)
+
+	bm, store, _, id := newOutboxManager(t, api.URL)
+
+	type result struct {
+		outbox *db.Outbox
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		outbox, err := bm.SendOutbox(ctx, id)
+		done <- result{outbox, err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not sent")
+	}
+
+	tx := make(chan error, 1)
+	go func() {
+		tx <- store.RunInTransaction(ctx, func(s storage.EventStore) error {
+			list, err := s.OutboxesByFilters(ctx, &db.OutboxSearch{ID: &id})
+			if err != nil {
+				return err
+			}
+			if o := list[0]; o.StatusID != db.EventStatusSending || o.Attempts != 1 {
+				return fmt.Errorf("message being sent has status %d, attempts %d", o.StatusID, o.Attempts)
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-tx:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("store is locked while message is being sent")
+	}
+
+	// message being sent is claimed by its sender
+	if _, err := bm.SendOutbox(ctx, id); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("second SendOutbox: %v, want ErrEventNotFound", err)
+	}
+
+	close(release)
+	res := <-done
+	if res.err != nil || res.outbox.StatusID != db.EventStatusSent {
+		t.Fatalf("SendOutbox = %+v, %v, want sent", res.outbox, res.err)
+	}
+}
+
+// TestSendOutboxExpiredLease checks that message of crashed sender is sent again after lease
+// and the late result of the crashed sender is not recorded.
+func TestSendOutboxExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+
+	bm, store, clk, id := newOutboxManager(t, api.URL())
+
+	// sender claims message and hangs
+	lost, err := bm.claimOutbox(ctx, id)
+	if err != nil {
+		t.Fatalf("claimOutbox: %v", err)
+	}
+
+	if _, err := bm.SendOutbox(ctx, id); !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("SendOutbox before lease expired: %v, want ErrEventNotFound", err)
+	}
+
+	clk.Advance(outboxLease + time.Second)
+	outbox, err := bm.SendOutbox(ctx, id)
+	if err != nil {
+		t.Fatalf("SendOutbox after lease expired: %v", err)
+	}
+	if outbox.StatusID != db.EventStatusSent || outbox.Attempts != 2 {
+		t.Errorf("message has status %d, attempts %d, want sent with 2 attempts", outbox.StatusID, outbox.Attempts)
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 1 {
+		t.Errorf("sent %d messages, want 1", len(calls))
+	}
+
+	if _, err := bm.recordOutbox(ctx, lost, errors.New("timeout")); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("recordOutbox of lost lease: %v, want ErrEventNotFound", err)
+	}
+	if o := outboxByID(t, store, id); o.StatusID != db.EventStatusSent {
+		t.Errorf("message status %d after lost lease result, want sent", o.StatusID)
+	}
+}
@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+)
+
+// callback data prefixes of reminder keyboard: "snooze:<id>:<option>" and "done:<id>"
+const (
+	CallbackSnooze = "snooze:"
+	CallbackDone   = "done:"
+)
+
+// snooze options
+const (
+	Snooze10Min   = "10m"
+	Snooze1Hour   = "1h"
+	SnoozeMorning = "morning"
+)
+
+// morningHour is the hour of "tomorrow morning" snooze option in user time zone.
+const morningHour = 9
+
+// reminderKeyboard returns inline keyboard attached to delivered reminder.
+func reminderKeyboard(id int) *models.InlineKeyboardMarkup {
+	snooze := func(text, option string) models.InlineKeyboardButton {
+		return models.InlineKeyboardButton{Text: text, CallbackData: fmt.Sprintf("%s%d:%s", CallbackSnooze, id, option)}
+	}
+
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{snooze("⏰ 10 мин", Snooze10Min), snooze("⏰ 1 час", Snooze1Hour), snooze("🌅 Завтра утром", SnoozeMorning)},
+			{{Text: "✅ Готово", CallbackData: fmt.Sprintf("%s%d", CallbackDone, id)}},
+		},
+	}
+}
+
+// ParseSnoozeData parses "snooze:<id>:<option>" callback data.
+func ParseSnoozeData(data string) (id int, option string, err error) {
+	idPart, option, ok := strings.Cut(strings.TrimPrefix(data, CallbackSnooze), ":")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid snooze data %q", data)
+	}
+
+	id, err = strconv.Atoi(idPart)
+	return id, option, err
+}
+
+// ParseDoneData parses "done:<id>" callback data.
+func ParseDoneData(data string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(data, CallbackDone))
+}
+
+// snoozeUntil returns new reminder time for snooze option in user time zone.
+func snoozeUntil(now time.Time, option string) (time.Time, error) {
+	switch option {
+	case Snooze10Min:
+		return now.Add(10 * time.Minute).Truncate(time.Minute), nil
+	case Snooze1Hour:
+		return now.Add(time.Hour).Truncate(time.Minute), nil
+	case SnoozeMorning:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, morningHour, 0, 0, 0, now.Location()), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid snooze option %q", option)
+}
+
+// SnoozeEvent reschedules delivered reminder on behalf of user. One-shot event is returned to pending state
+// with new time, for recurring event a one-shot copy is created so that its schedule is not changed.
+func (bm BotManager) SnoozeEvent(ctx context.Context, chatID, userID int64, id int, option string) (*model.Event, error) {
+	dbEvent, err := bm.store.OneEvent(ctx, &db.EventSearch{
+		UserEventID: &id,
+		UserTgID:    &chatID,
+		StatusIDs:   []int{db.EventStatusPending, db.EventStatusSent, db.EventStatusFailed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dbEvent == nil {
+		return nil, ErrEventNotFound
+	}
+
+	if err := bm.checkAccess(ctx, dbEvent, userID); err != nil {
+		return nil, err
+	}
+
+	loc := bm.Location(ctx, chatID)
+	until, err := snoozeUntil(bm.Now().In(loc), option)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbEvent.Recurrence != nil {
+		return bm.addEvent(ctx, &db.Event{
+			UserTgID:    chatID,
+			Message:     dbEvent.Message,
+			Tags:        dbEvent.Tags,
+			Mentions:    dbEvent.Mentions,
+			CreatorTgID: dbEvent.CreatorTgID,
+			SendAt:      until,
+			StatusID:    db.EventStatusPending,
+		}, nil, loc)
+	}
+
+	dbEvent.SendAt = until
+	dbEvent.SentAt = nil
+	dbEvent.StatusID = db.EventStatusPending
+	event, err := bm.updateEvent(ctx, dbEvent, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Notifications, err = bm.rescheduleNotifications(ctx, dbEvent, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// DoneCallbackHandler marks delivered reminder as done by removing its keyboard.
+func DoneCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update, bm *BotManager) {
+	cq := update.CallbackQuery
+	if msg := cq.Message.Message; msg != nil {
+		bm.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: msg.ID,
+			Text:      msg.Text + "\n\n✅ Готово",
+		})
+	}
+
+	bm.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: cq.ID})
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tagPrefix marks a tag in event text and /list filter: "#work".
+const tagPrefix = "#"
+
+// isTag reports whether word is a tag: "#" followed by a letter and letters, digits, "_" or "-".
+func isTag(word string) bool {
+	name := strings.TrimPrefix(word, tagPrefix)
+	if name == word || name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if i == 0 && !unicode.IsLetter(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitLabels cuts leading tags and mentions like "#work @alice @bob" from event text.
+// Tags are returned lowercased without "#" and duplicates, mentions keep "@".
+func splitLabels(text string) (tags, mentions []string, rest string) {
+	for {
+		first, tail, _ := strings.Cut(text, " ")
+		switch {
+		case isTag(first):
+			tags = appendTag(tags, first)
+		case isMention(first):
+			mentions = appendMention(mentions, first)
+		default:
+			return tags, mentions, text
+		}
+
+		text = strings.TrimSpace(tail)
+	}
+}
+
+func appendTag(tags []string, word string) []string {
+	tag := strings.ToLower(strings.TrimPrefix(word, tagPrefix))
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+
+	return append(tags, tag)
+}
+
+// tagsSuffix returns " 🏷 #work #billing" for tagged events and empty string otherwise.
+func tagsSuffix(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	return " 🏷 " + tagPrefix + strings.Join(tags, " "+tagPrefix)
+}
@@ -0,0 +1,82 @@
+package botService
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var handlerCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "bot_handler_calls_total",
+	Help: "Number of handled commands and button presses by handler.",
+}, []string{"handler"})
+
+func init() {
+	prometheus.MustRegister(handlerCalls)
+}
+
+// resolveUsername loads bot username to recognize commands addressed to the bot in groups.
+func (bs *BotService) resolveUsername() {
+	me, err := bs.b.GetMe(context.Background())
+	if err != nil {
+		log.Printf("Ошибка получения имени бота: %v", err)
+		return
+	}
+
+	bs.username = me.Username
+}
+
+// command registers handler of "/name" command. In groups command may be sent as "/name@BotName",
+// handler receives it without suffix. Exact commands do not accept arguments.
+func (bs *BotService) command(name string, matchType bot.MatchType, f bot.HandlerFunc) {
+	bs.b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		_, _, ok := bs.parseCommand(update, name, matchType)
+		return ok
+	}, func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		cmd, args, _ := bs.parseCommand(update, name, matchType)
+
+		msg := *update.Message
+		msg.Text = strings.TrimSpace(cmd + " " + args)
+		upd := *update
+		upd.Message = &msg
+
+		handlerCalls.WithLabelValues(name).Inc()
+		f(ctx, b, &upd)
+	})
+}
+
+// callback registers handler of inline buttons with callback data starting with prefix.
+func (bs *BotService) callback(prefix string, f bot.HandlerFunc) {
+	bs.b.RegisterHandler(bot.HandlerTypeCallbackQueryData, prefix, bot.MatchTypePrefix, func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		handlerCalls.WithLabelValues(prefix).Inc()
+		f(ctx, b, update)
+	})
+}
+
+// parseCommand splits "/name@BotName args" message text into command and arguments.
+func (bs *BotService) parseCommand(update *models.Update, name string, matchType bot.MatchType) (cmd, args string, ok bool) {
+	if update.Message == nil {
+		return "", "", false
+	}
+
+	first, args, _ := strings.Cut(strings.TrimSpace(update.Message.Text), " ")
+	cmd, target, addressed := strings.Cut(first, "@")
+	if cmd != name {
+		return "", "", false
+	}
+
+	if addressed && bs.username != "" && !strings.EqualFold(target, bs.username) {
+		return "", "", false
+	}
+
+	args = strings.TrimSpace(args)
+	if matchType == bot.MatchTypeExact && args != "" {
+		return "", "", false
+	}
+
+	return cmd, args, true
+}
@@ -0,0 +1,243 @@
+package botService
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/reminder"
+)
+
+// isPlainText matches text messages that are not commands.
+func isPlainText(update *models.Update) bool {
+	return update.Message != nil && update.Message.Text != "" && !strings.HasPrefix(update.Message.Text, "/")
+}
+
+// startDialog starts interactive /add dialog asking for event text.
+func (bs BotService) startDialog(ctx context.Context, b *bot.Bot, chatID, userID int64) {
+	if err := bs.dialogs.SaveDialog(ctx, botManager.Dialog{ChatID: chatID, UserID: userID, Step: botManager.DialogStepText}); err != nil {
+		log.Printf("Ошибка сохранения диалога: %v", err)
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "❌ Ошибка при добавлении события",
+		})
+		return
+	}
+
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   "✏️ Введите текст события (/cancel для отмены)",
+	})
+}
+
+func (bs BotService) CancelHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if err := bs.dialogs.DeleteDialog(ctx, update.Message.Chat.ID); err != nil {
+		log.Printf("Ошибка удаления диалога: %v", err)
+	}
+
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   "❌ Добавление отменено",
+	})
+}
+
+// DialogTextHandler handles text of event in /add dialog, other messages are passed to DefaultHandler.
+func (bs BotService) DialogTextHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	d, err := bs.dialogs.Dialog(ctx, chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки диалога: %v", err)
+	}
+	if d == nil || d.UserID != botManager.SenderID(update.Message) {
+		botManager.DefaultHandler(ctx, b, update, bs.bm)
+		return
+	}
+
+	if d.Step != botManager.DialogStepText {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "❗ Выберите значение на клавиатуре или отмените добавление: /cancel",
+		})
+		return
+	}
+
+	d.Text = strings.TrimSpace(update.Message.Text)
+	d.Step = botManager.DialogStepDate
+	if err := bs.dialogs.SaveDialog(ctx, *d); err != nil {
+		log.Printf("Ошибка сохранения диалога: %v", err)
+		return
+	}
+
+	now := bs.bm.Now().In(bs.bm.Location(ctx, chatID))
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        "📅 Выберите дату",
+		ReplyMarkup: botManager.CalendarKeyboard(now, now),
+	})
+}
+
+// DialogCallbackHandler handles calendar, time picker and confirm buttons of /add dialog.
+func (bs BotService) DialogCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	cq := update.CallbackQuery
+	msg := cq.Message.Message
+	if msg == nil {
+		bs.answerCallback(ctx, cq.ID, "❗ Сообщение недоступно")
+		return
+	}
+
+	action, value := botManager.ParseDialogData(cq.Data)
+	if action == botManager.DialogActionNoop {
+		bs.answerCallback(ctx, cq.ID, "")
+		return
+	}
+
+	chatID := msg.Chat.ID
+	d, err := bs.dialogs.Dialog(ctx, chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки диалога: %v", err)
+	}
+	if d == nil || d.Step == botManager.DialogStepText {
+		bs.editDialogMessage(ctx, msg, "❗ Диалог устарел, начните заново: /add", nil)
+		bs.answerCallback(ctx, cq.ID, "")
+		return
+	}
+
+	if d.UserID != cq.From.ID {
+		bs.answerCallback(ctx, cq.ID, "⛔ Событие добавляет другой участник")
+		return
+	}
+
+	loc := bs.bm.Location(ctx, chatID)
+	now := bs.bm.Now().In(loc)
+
+	switch action {
+	case botManager.DialogActionCancel:
+		if err := bs.dialogs.DeleteDialog(ctx, chatID); err != nil {
+			log.Printf("Ошибка удаления диалога: %v", err)
+		}
+		bs.editDialogMessage(ctx, msg, "❌ Добавление отменено", nil)
+
+	case botManager.DialogActionMonth:
+		month, err := time.ParseInLocation("2006-01", value, loc)
+		if err != nil {
+			break
+		}
+		bs.bm.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:      chatID,
+			MessageID:   msg.ID,
+			ReplyMarkup: botManager.CalendarKeyboard(month, now),
+		})
+
+	case botManager.DialogActionDate:
+		date, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			break
+		}
+		if date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)) {
+			bs.answerCallback(ctx, cq.ID, "❗ Дата уже прошла")
+			return
+		}
+
+		d.Step, d.SendAt = botManager.DialogStepTime, date
+		if !bs.saveDialog(ctx, cq.ID, *d) {
+			return
+		}
+		bs.editDialogMessage(ctx, msg, fmt.Sprintf("📅 %s\n🕒 Выберите час", date.Format("2006-01-02")), botManager.HourKeyboard())
+
+	case botManager.DialogActionHour:
+		hour, err := strconv.Atoi(value)
+		if err != nil || d.Step != botManager.DialogStepTime {
+			break
+		}
+		bs.bm.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:      chatID,
+			MessageID:   msg.ID,
+			ReplyMarkup: botManager.MinuteKeyboard(hour),
+		})
+
+	case botManager.DialogActionTime:
+		if d.Step != botManager.DialogStepTime {
+			break
+		}
+		dt, err := time.ParseInLocation("2006-01-02 15:04", d.SendAt.In(loc).Format("2006-01-02")+" "+value, loc)
+		if err != nil {
+			break
+		}
+		if dt.Before(now) {
+			bs.answerCallback(ctx, cq.ID, "❗ Это время уже прошло")
+			return
+		}
+
+		d.Step, d.SendAt = botManager.DialogStepConfirm, dt
+		if !bs.saveDialog(ctx, cq.ID, *d) {
+			return
+		}
+		bs.editDialogMessage(ctx, msg, fmt.Sprintf("📝 %s\n📅 %s (%s)\nСохранить?", d.Text, dt.Format("2006-01-02 15:04"), loc),
+			botManager.ConfirmKeyboard())
+
+	case botManager.DialogActionOK:
+		if d.Step != botManager.DialogStepConfirm {
+			break
+		}
+		d.SendAt = d.SendAt.In(loc)
+		bs.finishDialog(ctx, b, msg, *d)
+	}
+
+	bs.answerCallback(ctx, cq.ID, "")
+}
+
+// finishDialog adds event from confirmed dialog.
+func (bs BotService) finishDialog(ctx context.Context, b *bot.Bot, msg *models.Message, d botManager.Dialog) {
+	event, err := bs.bm.AddEventAt(ctx, d.ChatID, d.UserID, d.SendAt, d.Text)
+	if err != nil && err.Error() == "past_date" {
+		d.Step = botManager.DialogStepTime
+		if err := bs.dialogs.SaveDialog(ctx, d); err != nil {
+			log.Printf("Ошибка сохранения диалога: %v", err)
+		}
+		bs.editDialogMessage(ctx, msg, "❗ Это время уже прошло, выберите другое", botManager.HourKeyboard())
+		return
+	} else if err != nil {
+		log.Printf("Ошибка добавления события: %v", err)
+		bs.editDialogMessage(ctx, msg, "❌ Ошибка при добавлении события", nil)
+		return
+	}
+
+	if err := bs.dialogs.DeleteDialog(ctx, d.ChatID); err != nil {
+		log.Printf("Ошибка удаления диалога: %v", err)
+	}
+
+	bs.rm.ScheduleReminder(ctx, reminder.NewEvents(*event)...)
+
+	bs.editDialogMessage(ctx, msg,
+		fmt.Sprintf("✅ Событие добавлено на %s (%s)", event.DateTime.Format("2006-01-02 15:04"), event.DateTime.Location()), nil)
+}
+
+func (bs BotService) saveDialog(ctx context.Context, callbackID string, d botManager.Dialog) bool {
+	if err := bs.dialogs.SaveDialog(ctx, d); err != nil {
+		log.Printf("Ошибка сохранения диалога: %v", err)
+		bs.answerCallback(ctx, callbackID, "❌ Ошибка при добавлении события")
+		return false
+	}
+
+	return true
+}
+
+func (bs BotService) editDialogMessage(ctx context.Context, msg *models.Message, text string, markup *models.InlineKeyboardMarkup) {
+	params := &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      text,
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+
+	bs.bm.EditMessageText(ctx, params)
+}
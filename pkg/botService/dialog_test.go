@@ -0,0 +1,179 @@
+package botService_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/apptest"
+	"github.com/kanef1/event-reminder-bot/pkg/config"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+// testNow is 13:00 in Europe/Moscow, the default time zone of users.
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// dialogStep is a message or a button press of user in /add dialog and the expected reaction of the bot.
+type dialogStep struct {
+	// user is a sender of step, the first user of test by default
+	user int64
+	// advance moves clock before step
+	advance time.Duration
+	// text is sent as message if set, otherwise button with callback data is pressed under the last bot message
+	text string
+	data string
+	// hidden allows to press button which is not shown under the message, like outdated or forged one
+	hidden bool
+
+	// method of bot reply and substring of its text or of the answer to callback
+	method string
+	want   string
+	// button is callback data of a button which reply keyboard must have
+	button string
+}
+
+func TestDialog(t *testing.T) {
+	const user, member, group = int64(1), int64(2), int64(-100)
+
+	start := []dialogStep{
+		{text: "/add", method: "sendMessage", want: "✏️ Введите текст события"},
+		{text: "встреча", method: "sendMessage", want: "📅 Выберите дату", button: "add:date:2030-01-02"},
+	}
+	steps := func(more ...dialogStep) []dialogStep {
+		return append(append([]dialogStep(nil), start...), more...)
+	}
+
+	tests := []struct {
+		name  string
+		chat  int64
+		steps []dialogStep
+	}{
+		{"add event", user, steps(
+			dialogStep{data: "add:date:2030-01-02", method: "editMessageText", want: "📅 2030-01-02\n🕒 Выберите час", button: "add:hour:9"},
+			dialogStep{data: "add:hour:9", method: "editMessageReplyMarkup", button: "add:time:09:30"},
+			dialogStep{data: "add:time:09:30", method: "editMessageText", want: "📝 встреча\n📅 2030-01-02 09:30 (Europe/Moscow)\nСохранить?", button: "add:ok"},
+			dialogStep{data: "add:ok", method: "editMessageText", want: "✅ Событие добавлено на 2030-01-02 09:30 (Europe/Moscow)"},
+			dialogStep{text: "/list", method: "sendMessage", want: "встреча — 2030-01-02 09:30 (ID: 1)"},
+		)},
+		{"add event today", user, steps(
+			dialogStep{data: "add:date:2030-01-01", method: "editMessageText", want: "📅 2030-01-01", button: "add:hour:18"},
+			dialogStep{data: "add:hour:18", method: "editMessageReplyMarkup", button: "add:time:18:00"},
+			dialogStep{data: "add:time:18:00", method: "editMessageText", want: "2030-01-01 18:00", button: "add:ok"},
+			dialogStep{data: "add:ok", method: "editMessageText", want: "✅ Событие добавлено на 2030-01-01 18:00"},
+		)},
+		{"cancel by button", user, steps(
+			dialogStep{data: "add:cancel", method: "editMessageText", want: "❌ Добавление отменено"},
+			dialogStep{data: "add:date:2030-01-02", hidden: true, method: "editMessageText", want: "❗ Диалог устарел, начните заново: /add"},
+		)},
+		{"cancel by command", user, []dialogStep{
+			start[0],
+			{text: "/cancel", method: "sendMessage", want: "❌ Добавление отменено"},
+			{text: "/list", method: "sendMessage", want: "🔍 Нет событий"},
+		}},
+		{"cancel on confirm", user, steps(
+			dialogStep{data: "add:date:2030-01-02", method: "editMessageText", want: "Выберите час"},
+			dialogStep{data: "add:time:10:00", hidden: true, method: "editMessageText", want: "Сохранить?", button: "add:cancel"},
+			dialogStep{data: "add:cancel", method: "editMessageText", want: "❌ Добавление отменено"},
+		)},
+		{"expired dialog", user, steps(
+			dialogStep{advance: 25 * time.Hour, data: "add:date:2030-01-02", method: "editMessageText", want: "❗ Диалог устарел, начните заново: /add"},
+		)},
+		{"text instead of button", user, steps(
+			dialogStep{text: "завтра", method: "sendMessage", want: "❗ Выберите значение на клавиатуре или отмените добавление: /cancel"},
+		)},
+		{"next month", user, steps(
+			dialogStep{data: "add:month:2030-02", method: "editMessageReplyMarkup", button: "add:date:2030-02-28"},
+			dialogStep{data: "add:month:2030-01", method: "editMessageReplyMarkup", button: "add:date:2030-01-31"},
+		)},
+		{"past date", user, steps(
+			dialogStep{data: "add:date:2029-12-31", hidden: true, method: "answerCallbackQuery", want: "❗ Дата уже прошла"},
+		)},
+		{"past time", user, steps(
+			dialogStep{data: "add:date:2030-01-01", method: "editMessageText", want: "Выберите час"},
+			dialogStep{data: "add:time:09:00", hidden: true, method: "answerCallbackQuery", want: "❗ Это время уже прошло"},
+		)},
+		{"time before date", user, steps(
+			dialogStep{data: "add:time:09:00", hidden: true, method: "answerCallbackQuery"},
+			dialogStep{data: "add:date:2030-01-02", method: "editMessageText", want: "Выберите час"},
+		)},
+		{"other member", group, steps(
+			dialogStep{user: member, data: "add:date:2030-01-02", method: "answerCallbackQuery", want: "⛔ Событие добавляет другой участник"},
+			dialogStep{data: "add:date:2030-01-02", method: "editMessageText", want: "Выберите час"},
+		)},
+	}
+
+	for _, persist := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("persist=%t/%s", persist, tt.name), func(t *testing.T) {
+				cfg := config.Default()
+				cfg.Storage.Backend = storage.BackendMemory
+				cfg.Features.PersistDialogs = persist
+				// edits of dialog message would wait for real time limits of chat, they are tested by sender
+				cfg.Features.RateLimit = false
+
+				h := apptest.Start(apptest.Config{App: &cfg, Now: testNow})
+				t.Cleanup(h.Close)
+
+				var last telegramtest.Call
+				for i, s := range tt.steps {
+					if s.user == 0 {
+						s.user = user
+					}
+					h.Clock.Advance(s.advance)
+
+					if s.text != "" {
+						h.API.Message(tt.chat, s.user, s.text)
+					} else {
+						if !s.hidden && !hasButton(last, s.data) {
+							t.Fatalf("step %d: no button %q under %q", i, s.data, last.Text())
+						}
+						if err := h.API.Callback(last.MessageID(), s.user, s.data); err != nil {
+							t.Fatalf("step %d: %v", i, err)
+						}
+					}
+
+					answer := telegramtest.Call{}
+					if s.text == "" {
+						var err error
+						if answer, err = h.API.Wait("answerCallbackQuery", apptest.Timeout); err != nil {
+							t.Fatalf("step %d: %v", i, err)
+						}
+					}
+
+					call := answer
+					if s.method != "answerCallbackQuery" {
+						var err error
+						if call, err = h.API.Wait(s.method, apptest.Timeout); err != nil {
+							t.Fatalf("step %d: %v", i, err)
+						}
+					}
+
+					if !strings.Contains(call.Text(), s.want) {
+						t.Fatalf("step %d: %s %q, want %q", i, s.method, call.Text(), s.want)
+					}
+					if s.button != "" && !hasButton(call, s.button) {
+						t.Fatalf("step %d: no button %q in reply keyboard", i, s.button)
+					}
+
+					if s.method != "answerCallbackQuery" {
+						last = call
+					}
+				}
+			})
+		}
+	}
+}
+
+func hasButton(call telegramtest.Call, data string) bool {
+	for _, row := range call.Keyboard() {
+		for _, button := range row {
+			if button.CallbackData == data {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -2,83 +2,298 @@ package botService
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
 	"github.com/kanef1/event-reminder-bot/pkg/reminder"
 )
 
 type BotService struct {
-	b  *bot.Bot
-	bm *botManager.BotManager
-	rm *reminder.ReminderManager
+	b       *bot.Bot
+	bm      *botManager.BotManager
+	rm      *reminder.ReminderManager
+	dialogs botManager.DialogStore
+	// username of the bot, commands like "/list@username" addressed to other bots are ignored
+	username string
 }
 
-func NewBotService(b *bot.Bot, bm *botManager.BotManager, rm *reminder.ReminderManager) *BotService {
-	return &BotService{b: b, bm: bm, rm: rm}
+func NewBotService(b *bot.Bot, bm *botManager.BotManager, rm *reminder.ReminderManager, dialogs botManager.DialogStore) *BotService {
+	return &BotService{b: b, bm: bm, rm: rm, dialogs: dialogs}
 }
 
 func (bs *BotService) RegisterHandlers() {
-	bs.b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypeExact, botManager.StartHandler)
-	bs.b.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypeExact, botManager.HelpHandler)
-	bs.b.RegisterHandler(bot.HandlerTypeMessageText, "/add", bot.MatchTypePrefix, bs.AddHandler)
-	bs.b.RegisterHandler(bot.HandlerTypeMessageText, "/list", bot.MatchTypeExact, bs.listHandler)
-	bs.b.RegisterHandler(bot.HandlerTypeMessageText, "/delete", bot.MatchTypePrefix, bs.deleteHandler)
+	bs.resolveUsername()
+
+	bs.command("/start", bot.MatchTypeExact, bs.startHandler)
+	bs.command("/help", bot.MatchTypeExact, bs.helpHandler)
+	bs.command("/add", bot.MatchTypePrefix, bs.AddHandler)
+	bs.command("/list", bot.MatchTypePrefix, bs.listHandler)
+	bs.command("/find", bot.MatchTypePrefix, bs.findHandler)
+	bs.command("/today", bot.MatchTypeExact, bs.periodHandler)
+	bs.command("/tomorrow", bot.MatchTypeExact, bs.periodHandler)
+	bs.command("/week", bot.MatchTypeExact, bs.periodHandler)
+	bs.command("/history", bot.MatchTypeExact, bs.historyHandler)
+	bs.command("/delete", bot.MatchTypePrefix, bs.DeleteHandler)
+	bs.command("/edit", bot.MatchTypePrefix, bs.EditHandler)
+	bs.command("/timezone", bot.MatchTypePrefix, bs.timezoneHandler)
+	bs.command("/cancel", bot.MatchTypeExact, bs.CancelHandler)
+	bs.b.RegisterHandlerMatchFunc(isPlainText, bs.DialogTextHandler)
+	bs.callback(botManager.CallbackSnooze, bs.SnoozeCallbackHandler)
+	bs.callback(botManager.CallbackDone, bs.doneCallbackHandler)
+	bs.callback(botManager.CallbackDialog, bs.DialogCallbackHandler)
+	bs.callback(botManager.CallbackList, bs.ListCallbackHandler)
+}
+
+// DefaultHandler answers messages not matched by other handlers.
+func (bs *BotService) DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.DefaultHandler(ctx, b, update, bs.bm)
+}
+
+func (bs *BotService) startHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.StartHandler(ctx, b, update, bs.bm)
+}
+
+func (bs *BotService) doneCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.DoneCallbackHandler(ctx, b, update, bs.bm)
+}
+
+func (bs *BotService) helpHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.HelpHandler(ctx, b, update, bs.bm)
 }
 
-func (bs *BotService) deleteHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	botManager.DeleteHandler(ctx, b, update, bs.bm)
+func (bs *BotService) timezoneHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.TimezoneHandler(ctx, b, update, bs.bm)
+}
+
+func (bs *BotService) historyHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.HistoryHandler(ctx, b, update, bs.bm)
 }
 
 func (bs *BotService) listHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	botManager.ListHandler(ctx, b, update, bs.bm)
 }
 
+func (bs *BotService) findHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.FindHandler(ctx, b, update, bs.bm)
+}
+
+func (bs *BotService) periodHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	botManager.PeriodHandler(ctx, b, update, bs.bm)
+}
+
 func (bs BotService) AddHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	args := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/add"))
+
+	var (
+		event *model.Event
+		err   error
+	)
+	if strings.HasPrefix(args, "every ") {
+		parts := strings.SplitN(args, " ", 4)
+		if len(parts) < 4 {
+			bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   "❗ Формат: /add every mon,wed 09:30 Текст",
+			})
+			return
+		}
+		event, err = bs.bm.AddRecurringEvent(ctx, update.Message.Chat.ID, botManager.SenderID(update.Message), parts[1:])
+	} else {
+		if args == "" {
+			bs.startDialog(ctx, b, update.Message.Chat.ID, botManager.SenderID(update.Message))
+			return
+		}
+		event, err = bs.bm.AddEvent(ctx, update.Message.Chat.ID, botManager.SenderID(update.Message), args)
+	}
+
+	if err != nil {
+		var text string
+		switch err.Error() {
+		case "invalid_format":
+			text = "❗ Не удалось распознать дату (используйте YYYY-MM-DD HH:MM, «завтра в 9» или «через 2 часа»)"
+		case "empty_text":
+			text = "❗ Текст события не может быть пустым"
+		case "past_date":
+			text = "❗ Недопустимый формат даты (событие должно быть в будущем)"
+		case "invalid_recurrence":
+			text = "❗ Недопустимое правило повтора (используйте day, weekday, mon,wed или 1,15)"
+		default:
+			log.Printf("Ошибка добавления события: %v", err)
+			text = "❌ Ошибка при добавлении события"
+		}
+
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   text,
+		})
+		return
+	}
+
+	bs.rm.ScheduleReminder(ctx, reminder.NewEvents(*event)...)
+
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   fmt.Sprintf("✅ Событие добавлено на %s (%s)", event.DateTime.Format("2006-01-02 15:04"), event.DateTime.Location()),
+	})
+}
+
+func (bs BotService) EditHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/edit"))
 	parts := strings.SplitN(args, " ", 3)
 	if len(parts) < 3 {
-		b.SendMessage(ctx, &bot.SendMessageParams{
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ Формат: /edit 123 2025-08-06 15:00 или /edit 123 text Новый текст",
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❗ Формат: /add 2025-08-06 15:00 Текст",
+			Text:   "❗ ID должен быть числом",
 		})
 		return
 	}
 
-	event, err := bs.bm.AddEvent(ctx, update.Message.Chat.ID, parts)
+	var event *model.Event
+	if parts[1] == "text" {
+		event, err = bs.bm.UpdateEventText(ctx, update.Message.Chat.ID, botManager.SenderID(update.Message), id, parts[2])
+	} else {
+		event, err = bs.bm.UpdateEventTime(ctx, update.Message.Chat.ID, botManager.SenderID(update.Message), id, parts[1], parts[2])
+	}
+
 	if err != nil {
 		var text string
-		switch err.Error() {
-		case "invalid_format":
+		switch {
+		case errors.Is(err, botManager.ErrEventNotFound):
+			text = "❗ Событие с таким ID не найдено"
+		case errors.Is(err, botManager.ErrForbidden):
+			text = "⛔ Изменить событие может только его автор или администратор чата"
+		case err.Error() == "invalid_format":
 			text = "❗ Недопустимый формат даты (используйте YYYY-MM-DD HH:MM)"
-		case "past_date":
+		case err.Error() == "past_date":
 			text = "❗ Недопустимый формат даты (событие должно быть в будущем)"
+		case err.Error() == "empty_text":
+			text = "❗ Текст события не может быть пустым"
 		default:
-			text = fmt.Sprintf("Ошибка: %v", err)
+			log.Printf("Ошибка изменения события: %v", err)
+			text = "❌ Ошибка при изменении события"
 		}
 
-		b.SendMessage(ctx, &bot.SendMessageParams{
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
 			Text:   text,
 		})
 		return
 	}
 
-	reminderEvent := reminder.Event{
-		ID:         event.ID,
-		OriginalID: event.OriginalID,
-		ChatID:     event.ChatID,
-		Text:       event.Text,
-		DateTime:   event.DateTime,
+	bs.rm.RescheduleReminder(ctx, reminder.NewEvents(*event)...)
+
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   fmt.Sprintf("✅ Событие изменено: %s — %s", event.Text, event.DateTime.Format("2006-01-02 15:04")),
+	})
+}
+
+func (bs BotService) DeleteHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/delete"))
+	if args == "" {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ Укажите ID события, например: /delete 123",
+		})
+		return
 	}
 
-	bs.rm.ScheduleReminder(ctx, reminderEvent)
+	id, err := strconv.Atoi(args)
+	if err != nil {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ ID должен быть числом",
+		})
+		return
+	}
 
-	b.SendMessage(ctx, &bot.SendMessageParams{
+	event, err := bs.bm.DeleteEventByID(ctx, update.Message.Chat.ID, botManager.SenderID(update.Message), id)
+	if errors.Is(err, botManager.ErrEventNotFound) {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❗ Событие с таким ID не найдено",
+		})
+		return
+	} else if errors.Is(err, botManager.ErrForbidden) {
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "⛔ Удалить событие может только его автор или администратор чата",
+		})
+		return
+	} else if err != nil {
+		log.Printf("Ошибка удаления события: %v", err)
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "❌ Ошибка при удалении события",
+		})
+		return
+	}
+
+	bs.rm.CancelReminder(event.OriginalID)
+
+	bs.bm.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
-		Text:   "✅ Событие добавлено!",
+		Text:   "✅ Событие удалено!",
+	})
+}
+
+func (bs BotService) SnoozeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	cq := update.CallbackQuery
+	msg := cq.Message.Message
+	if msg == nil {
+		bs.answerCallback(ctx, cq.ID, "❗ Сообщение недоступно")
+		return
+	}
+
+	id, option, err := botManager.ParseSnoozeData(cq.Data)
+	if err != nil {
+		log.Printf("Ошибка разбора callback %q: %v", cq.Data, err)
+		bs.answerCallback(ctx, cq.ID, "❌ Ошибка при переносе напоминания")
+		return
+	}
+
+	event, err := bs.bm.SnoozeEvent(ctx, msg.Chat.ID, cq.From.ID, id, option)
+	if errors.Is(err, botManager.ErrEventNotFound) {
+		bs.answerCallback(ctx, cq.ID, "❗ Событие не найдено")
+		return
+	} else if errors.Is(err, botManager.ErrForbidden) {
+		bs.answerCallback(ctx, cq.ID, "⛔ Отложить событие может только его автор или администратор чата")
+		return
+	} else if err != nil {
+		log.Printf("Ошибка переноса напоминания: %v", err)
+		bs.answerCallback(ctx, cq.ID, "❌ Ошибка при переносе напоминания")
+		return
+	}
+
+	bs.rm.RescheduleReminder(ctx, reminder.NewEvents(*event)...)
+
+	bs.bm.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      msg.Text + "\n\n⏰ Отложено до " + event.DateTime.Format("2006-01-02 15:04"),
+	})
+	bs.answerCallback(ctx, cq.ID, "")
+}
+
+func (bs BotService) answerCallback(ctx context.Context, id, text string) {
+	bs.bm.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: id,
+		Text:            text,
 	})
 }
@@ -0,0 +1,91 @@
+package botService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+	"github.com/kanef1/event-reminder-bot/pkg/reminder"
+)
+
+// ListCallbackHandler handles page navigation and per-event buttons of /list, editing the list message in place.
+func (bs BotService) ListCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	cq := update.CallbackQuery
+	msg := cq.Message.Message
+	if msg == nil {
+		bs.answerCallback(ctx, cq.ID, "❗ Сообщение недоступно")
+		return
+	}
+
+	action, id, page, spec, err := botManager.ParseListData(cq.Data)
+	if err != nil {
+		log.Printf("Ошибка разбора callback %q: %v", cq.Data, err)
+		bs.answerCallback(ctx, cq.ID, "❌ Ошибка")
+		return
+	}
+
+	chatID := msg.Chat.ID
+	var answer string
+
+	switch action {
+	case botManager.ListActionPage:
+	case botManager.ListActionDelete:
+		var event *model.Event
+		if event, err = bs.bm.DeleteEventByID(ctx, chatID, cq.From.ID, id); err == nil {
+			bs.rm.CancelReminder(event.OriginalID)
+		}
+		answer = "✅ Событие удалено!"
+	case botManager.ListActionSnooze:
+		var events []model.Event
+		events, err = bs.bm.PostponeEvent(ctx, chatID, cq.From.ID, id)
+		for _, e := range events {
+			bs.rm.RescheduleReminder(ctx, reminder.NewEvents(e)...)
+		}
+		answer = "⏰ Событие отложено на час"
+	case botManager.ListActionEdit:
+		bs.bm.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("✏️ Изменить событие %d: /edit %d 2025-08-06 15:00 или /edit %d text Новый текст", id, id, id),
+		})
+		bs.answerCallback(ctx, cq.ID, "")
+		return
+	default:
+		bs.answerCallback(ctx, cq.ID, "")
+		return
+	}
+
+	if errors.Is(err, botManager.ErrEventNotFound) {
+		answer = "❗ Событие не найдено"
+	} else if errors.Is(err, botManager.ErrForbidden) {
+		bs.answerCallback(ctx, cq.ID, "⛔ Изменить событие может только его автор или администратор чата")
+		return
+	} else if err != nil {
+		log.Printf("Ошибка обработки списка событий: %v", err)
+		bs.answerCallback(ctx, cq.ID, "❌ Ошибка при изменении события")
+		return
+	}
+
+	text, markup, err := bs.bm.ListPage(ctx, chatID, spec, page)
+	if err != nil {
+		log.Printf("Ошибка загрузки событий: %v", err)
+		bs.answerCallback(ctx, cq.ID, "❌ Ошибка при загрузке событий")
+		return
+	}
+
+	params := &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: msg.ID,
+		Text:      text,
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+
+	bs.bm.EditMessageText(ctx, params)
+	bs.answerCallback(ctx, cq.ID, answer)
+}
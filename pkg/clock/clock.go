@@ -0,0 +1,187 @@
+// Package clock abstracts current time and timers, so time-based behavior can be driven by Fake clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells current time and creates timers.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is time.Timer of Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is time.Ticker of Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is a system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Fake is a clock that moves only by Advance and Set. Its timers and tickers fire when time passes their deadlines.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+// NewFake returns Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, timers: make(map[*fakeTimer]struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Advance moves clock forward by d firing due timers.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves clock to t firing due timers. Clock never moves back.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t.After(f.now) {
+		f.now = t
+	}
+	for timer := range f.timers {
+		timer.fire(f.now)
+	}
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return fakeTicker{t}
+}
+
+// fakeTimer is a timer or a ticker if period is set.
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// fire sends time to channel if deadline has passed. Clock mutex must be held.
+func (t *fakeTimer) fire(now time.Time) {
+	if now.Before(t.deadline) {
+		return
+	}
+
+	// like time.Ticker, slow receiver gets a single tick
+	select {
+	case t.c <- now:
+	default:
+	}
+
+	if t.period == 0 {
+		delete(t.clock.timers, t)
+		return
+	}
+	for !now.Before(t.deadline) {
+		t.deadline = t.deadline.Add(t.period)
+	}
+}
+
+// Stop stops timer and drains its channel like time.Timer since Go 1.23.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	t.drain()
+
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.timers[t]
+	t.drain()
+
+	t.deadline = t.clock.now.Add(d)
+	t.clock.timers[t] = struct{}{}
+	t.fire(t.clock.now)
+
+	return active
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
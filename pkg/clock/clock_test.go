@@ -0,0 +1,155 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// fired returns time received from c or false if nothing is sent.
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(testNow)
+	timer := f.NewTimer(time.Minute)
+
+	f.Advance(59 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired before deadline")
+	}
+
+	f.Advance(time.Second)
+	if got, ok := fired(timer.C()); !ok || !got.Equal(testNow.Add(time.Minute)) {
+		t.Fatalf("timer fired %t at %s, want at %s", ok, got, testNow.Add(time.Minute))
+	}
+
+	// fired timer does not fire again
+	f.Advance(time.Hour)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired twice")
+	}
+	if timer.Stop() {
+		t.Error("Stop of fired timer returned true")
+	}
+}
+
+func TestFakeTimerNonPositive(t *testing.T) {
+	f := NewFake(testNow)
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, ok := fired(f.NewTimer(d).C()); !ok {
+			t.Errorf("timer of %s did not fire immediately", d)
+		}
+	}
+}
+
+func TestFakeTimerStop(t *testing.T) {
+	f := NewFake(testNow)
+	timer := f.NewTimer(time.Minute)
+
+	if !timer.Stop() {
+		t.Error("Stop of active timer returned false")
+	}
+	f.Advance(time.Hour)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	// Stop drains fired but not received value
+	timer = f.NewTimer(time.Minute)
+	f.Advance(time.Minute)
+	timer.Stop()
+	if _, ok := fired(timer.C()); ok {
+		t.Error("Stop did not drain channel")
+	}
+}
+
+func TestFakeTimerReset(t *testing.T) {
+	f := NewFake(testNow)
+	timer := f.NewTimer(time.Minute)
+
+	f.Advance(30 * time.Second)
+	if !timer.Reset(time.Minute) {
+		t.Error("Reset of active timer returned false")
+	}
+
+	// deadline is counted from the time of Reset
+	f.Advance(59 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired before new deadline")
+	}
+	f.Advance(time.Second)
+	if _, ok := fired(timer.C()); !ok {
+		t.Fatal("timer did not fire at new deadline")
+	}
+
+	if timer.Reset(time.Minute) {
+		t.Error("Reset of fired timer returned true")
+	}
+	f.Advance(time.Minute)
+	if _, ok := fired(timer.C()); !ok {
+		t.Fatal("timer did not fire after Reset")
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(testNow)
+	ticker := f.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		f.Advance(time.Minute)
+		if got, ok := fired(ticker.C()); !ok || !got.Equal(testNow.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("tick %d fired %t at %s", i, ok, got)
+		}
+	}
+
+	// slow receiver gets a single tick, the next one is on schedule
+	f.Advance(150 * time.Second)
+	if _, ok := fired(ticker.C()); !ok {
+		t.Fatal("ticker did not fire")
+	}
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("ticker fired twice for one Advance")
+	}
+	f.Advance(29 * time.Second)
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("ticker fired before period")
+	}
+	f.Advance(time.Second)
+	if _, ok := fired(ticker.C()); !ok {
+		t.Fatal("ticker did not fire on schedule")
+	}
+
+	ticker.Stop()
+	f.Advance(time.Hour)
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("stopped ticker fired")
+	}
+}
+
+func TestFakeTickerNonPositive(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewTicker(0) did not panic")
+		}
+	}()
+
+	NewFake(testNow).NewTicker(0)
+}
+
+func TestFakeSetNeverMovesBack(t *testing.T) {
+	f := NewFake(testNow)
+	f.Set(testNow.Add(-time.Hour))
+	if !f.Now().Equal(testNow) {
+		t.Errorf("Now = %s after Set to the past, want %s", f.Now(), testNow)
+	}
+}
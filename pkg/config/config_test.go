@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// setEnv sets minimal valid environment and then vars.
+func setEnv(t *testing.T, vars map[string]string) {
+	t.Helper()
+
+	t.Setenv("TELEGRAM_TOKEN", "123456:TEST")
+	t.Setenv("STORAGE", "memory")
+	for k, v := range vars {
+		t.Setenv(k, v)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	setEnv(t, map[string]string{"TELEGRAM_TOKEN": "", "RATE_LIMIT_CHAT": "быстро", "TIMEZONE": "Mars/Olympus"})
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("Load succeeded")
+	}
+	for _, want := range []string{"RATE_LIMIT_CHAT", "TELEGRAM_TOKEN", "TIMEZONE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadLogLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		ok    bool
+	}{
+		{LogInfo, true},
+		{LogDebug, true},
+		{"warn", false},
+		{"error", false},
+		{"trace", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			setEnv(t, map[string]string{"LOG_LEVEL": tt.level})
+
+			cfg, err := Load("")
+			if tt.ok && (err != nil || cfg.LogLevel != tt.level) {
+				t.Fatalf("Load = %q, %v", cfg.LogLevel, err)
+			}
+			if !tt.ok && (err == nil || !strings.Contains(err.Error(), "LOG_LEVEL")) {
+				t.Fatalf("Load error %v, want invalid LOG_LEVEL", err)
+			}
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	setEnv(t, map[string]string{"WEBHOOK_DELETE_ON_STOP": "true", "DIALOG_STORE": "db"})
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Server.DeleteWebhook {
+		t.Error("WEBHOOK_DELETE_ON_STOP is not applied")
+	}
+	if cfg.Features.PersistDialogs {
+		t.Error("DIALOG_STORE is applied, only PERSIST_DIALOGS selects dialog store")
+	}
+}
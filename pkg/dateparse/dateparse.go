@@ -0,0 +1,352 @@
+// Package dateparse parses natural-language date expressions in Russian and English,
+// e.g. "завтра в 9", "через 2 часа", "in 30 min", "next friday 18:00".
+package dateparse
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrNoDate is returned when text does not start with a date expression.
+var ErrNoDate = errors.New("no date expression")
+
+// defaultHour is the time of day used when expression has a day but no time, e.g. "завтра".
+const defaultHour = 9
+
+var dayWords = map[string]int{
+	"сегодня":     0,
+	"today":       0,
+	"завтра":      1,
+	"tomorrow":    1,
+	"послезавтра": 2,
+}
+
+var weekdayWords = map[string]time.Weekday{
+	"понедельник": time.Monday, "пн": time.Monday, "monday": time.Monday, "mon": time.Monday,
+	"вторник": time.Tuesday, "вт": time.Tuesday, "tuesday": time.Tuesday, "tue": time.Tuesday,
+	"среду": time.Wednesday, "среда": time.Wednesday, "ср": time.Wednesday, "wednesday": time.Wednesday, "wed": time.Wednesday,
+	"четверг": time.Thursday, "чт": time.Thursday, "thursday": time.Thursday, "thu": time.Thursday,
+	"пятницу": time.Friday, "пятница": time.Friday, "пт": time.Friday, "friday": time.Friday, "fri": time.Friday,
+	"субботу": time.Saturday, "суббота": time.Saturday, "сб": time.Saturday, "saturday": time.Saturday, "sat": time.Saturday,
+	"воскресенье": time.Sunday, "вс": time.Sunday, "sunday": time.Sunday, "sun": time.Sunday,
+}
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "один": 1, "одну": 1, "одна": 1,
+	"two": 2, "два": 2, "две": 2,
+	"three": 3, "три": 3,
+	"four": 4, "четыре": 4,
+	"five": 5, "пять": 5,
+	"six": 6, "шесть": 6,
+	"seven": 7, "семь": 7,
+	"eight": 8, "восемь": 8,
+	"nine": 9, "девять": 9,
+	"ten": 10, "десять": 10,
+	"fifteen": 15, "пятнадцать": 15,
+	"twenty": 20, "двадцать": 20,
+	"thirty": 30, "тридцать": 30,
+}
+
+var (
+	relativeWords = []string{"через", "in"}
+	atWords       = []string{"в", "во", "at"}
+	onWords       = []string{"в", "во", "on"}
+	nextWords     = []string{"next", "следующий", "следующую", "следующее", "следующей"}
+	pmWords       = []string{"pm", "дня", "вечера"}
+	amWords       = []string{"am", "утра", "ночи"}
+	hourWords     = []string{"ч", "час", "часа", "часов"}
+)
+
+// Parse parses date expression at the beginning of s relative to now and returns the time
+// in now's location and the remaining text. ErrNoDate is returned if s has no leading date expression.
+func Parse(s string, now time.Time) (time.Time, string, error) {
+	p := parser{tokens: strings.Fields(strings.ToLower(s)), now: now}
+
+	t, ok := p.parse()
+	if !ok {
+		return time.Time{}, s, ErrNoDate
+	}
+
+	return t, skipFields(s, p.pos), nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	now    time.Time
+}
+
+func (p *parser) parse() (time.Time, bool) {
+	if t, ok := p.relative(); ok {
+		return t, true
+	}
+
+	date, hasDate := p.day()
+	hour, minute, hasTime := p.clock()
+	if !hasDate && hasTime {
+		// "в 9 завтра", "18:00 tomorrow"
+		date, hasDate = p.day()
+	}
+
+	switch {
+	case !hasDate && !hasTime:
+		return time.Time{}, false
+	case !hasTime:
+		hour, minute = defaultHour, 0
+	case !hasDate:
+		date = p.today()
+		if t := at(date, hour, minute); !t.After(p.now) {
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+
+	return at(date, hour, minute), true
+}
+
+// relative parses "через 2 часа", "in 30 min", "через 2 дня в 10:00".
+func (p *parser) relative() (time.Time, bool) {
+	start := p.pos
+	if !p.accept(relativeWords...) {
+		return time.Time{}, false
+	}
+
+	n, unit, ok := p.amount()
+	if !ok {
+		p.pos = start
+		return time.Time{}, false
+	}
+
+	if unit < 24*time.Hour {
+		return p.now.Add(time.Duration(n) * unit).Truncate(time.Minute), true
+	}
+
+	date := p.today().AddDate(0, 0, n*int(unit/(24*time.Hour)))
+	if hour, minute, ok := p.clock(); ok {
+		return at(date, hour, minute), true
+	}
+
+	return at(date, p.now.Hour(), p.now.Minute()), true
+}
+
+// amount parses "2 часа", "30min", "час", "полчаса", "an hour".
+func (p *parser) amount() (int, time.Duration, bool) {
+	tok, ok := p.peek()
+	if !ok {
+		return 0, 0, false
+	}
+
+	if tok == "полчаса" {
+		p.pos++
+		return 30, time.Minute, true
+	}
+
+	if unit, ok := parseUnit(tok); ok {
+		p.pos++
+		return 1, unit, true
+	}
+
+	// glued number and unit: "30min", "2h"
+	if i := strings.IndexFunc(tok, func(r rune) bool { return !unicode.IsDigit(r) }); i > 0 {
+		n, err := strconv.Atoi(tok[:i])
+		if unit, ok := parseUnit(tok[i:]); ok && err == nil && n > 0 {
+			p.pos++
+			return n, unit, true
+		}
+	}
+
+	n, ok := parseNumber(tok)
+	if !ok || n <= 0 || p.pos+1 >= len(p.tokens) {
+		return 0, 0, false
+	}
+
+	unit, ok := parseUnit(p.tokens[p.pos+1])
+	if !ok {
+		return 0, 0, false
+	}
+
+	p.pos += 2
+	return n, unit, true
+}
+
+// day parses "сегодня", "завтра", "послезавтра", "day after tomorrow", "в пятницу", "next friday".
+func (p *parser) day() (time.Time, bool) {
+	start := p.pos
+	today := p.today()
+
+	if tok, ok := p.peek(); ok {
+		if n, ok := dayWords[tok]; ok {
+			p.pos++
+			return today.AddDate(0, 0, n), true
+		}
+	}
+
+	if p.accept("day") && p.accept("after") && p.accept("tomorrow") {
+		return today.AddDate(0, 0, 2), true
+	}
+	p.pos = start
+
+	p.accept(onWords...)
+	p.accept(nextWords...)
+	if tok, ok := p.peek(); ok {
+		if wd, ok := weekdayWords[tok]; ok {
+			p.pos++
+			days := (int(wd) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return today.AddDate(0, 0, days), true
+		}
+	}
+
+	p.pos = start
+	return time.Time{}, false
+}
+
+// clock parses "18:00", "в 9", "at 9pm", "в 7 вечера", "at 10.30".
+func (p *parser) clock() (hour, minute int, ok bool) {
+	start := p.pos
+	prefixed := p.accept(atWords...)
+
+	tok, ok := p.peek()
+	if !ok {
+		p.pos = start
+		return 0, 0, false
+	}
+
+	suffix := ""
+	for _, s := range append(append([]string{}, pmWords...), amWords...) {
+		if strings.HasSuffix(tok, s) && len(tok) > len(s) && unicode.IsDigit(rune(tok[len(tok)-len(s)-1])) {
+			tok, suffix = strings.TrimSuffix(tok, s), s
+			break
+		}
+	}
+
+	hour, minute, hasMinutes := parseClock(tok)
+	if hour < 0 {
+		p.pos = start
+		return 0, 0, false
+	}
+	p.pos++
+
+	if suffix == "" {
+		// "в 7 вечера", "в 3 часа дня"
+		if next, ok := p.peek(); ok && contains(hourWords, next) {
+			p.pos++
+			prefixed = true
+		}
+		if next, ok := p.peek(); ok && (contains(pmWords, next) || contains(amWords, next)) {
+			suffix = next
+			p.pos++
+		}
+	}
+
+	// bare number is a time only with "в"/"at" or am/pm marker
+	if !hasMinutes && !prefixed && suffix == "" {
+		p.pos = start
+		return 0, 0, false
+	}
+
+	switch {
+	case contains(pmWords, suffix) && hour < 12:
+		hour += 12
+	case contains(amWords, suffix) && hour == 12:
+		hour = 0
+	}
+
+	return hour, minute, true
+}
+
+func (p *parser) today() time.Time {
+	return time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes next token if it is one of words.
+func (p *parser) accept(words ...string) bool {
+	if tok, ok := p.peek(); ok && contains(words, tok) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseClock parses "18:00", "18.00" or "9". Hour is -1 if token is not a time.
+func parseClock(tok string) (hour, minute int, hasMinutes bool) {
+	h, m, found := strings.Cut(strings.Replace(tok, ".", ":", 1), ":")
+
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 23 {
+		return -1, 0, false
+	}
+
+	if !found {
+		return hour, 0, false
+	}
+
+	minute, err = strconv.Atoi(m)
+	if err != nil || len(m) != 2 || minute > 59 {
+		return -1, 0, false
+	}
+
+	return hour, minute, true
+}
+
+func parseNumber(tok string) (int, bool) {
+	if n, ok := numberWords[tok]; ok {
+		return n, true
+	}
+
+	n, err := strconv.Atoi(tok)
+	return n, err == nil
+}
+
+func parseUnit(tok string) (time.Duration, bool) {
+	switch {
+	case contains([]string{"m", "min", "mins", "minute", "minutes"}, tok), strings.HasPrefix(tok, "мин"):
+		return time.Minute, true
+	case contains([]string{"h", "hr", "hrs", "hour", "hours", "ч", "час", "часа", "часов"}, tok):
+		return time.Hour, true
+	case contains([]string{"d", "day", "days", "день", "дня", "дней", "сутки", "суток"}, tok):
+		return 24 * time.Hour, true
+	case contains([]string{"w", "week", "weeks"}, tok), strings.HasPrefix(tok, "недел"):
+		return 7 * 24 * time.Hour, true
+	}
+
+	return 0, false
+}
+
+func at(date time.Time, hour, minute int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+}
+
+func contains(words []string, tok string) bool {
+	for _, w := range words {
+		if w == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipFields returns s without its first n whitespace-separated fields.
+func skipFields(s string, n int) string {
+	for i := 0; i < n; i++ {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if j := strings.IndexFunc(s, unicode.IsSpace); j >= 0 {
+			s = s[j:]
+		} else {
+			s = ""
+		}
+	}
+
+	return strings.TrimSpace(s)
+}
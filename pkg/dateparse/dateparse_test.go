@@ -0,0 +1,121 @@
+package dateparse
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const layout = "2006-01-02 15:04"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // in layout, empty if ErrNoDate is expected
+		rest string
+	}{
+		// now is Wednesday 2025-09-03 14:30
+		{"завтра в 9 встреча", "2025-09-04 09:00", "встреча"},
+		{"через 2 часа позвонить маме", "2025-09-03 16:30", "позвонить маме"},
+		{"in 30 min call", "2025-09-03 15:00", "call"},
+		{"next friday 18:00 party", "2025-09-05 18:00", "party"},
+		{"в 7 вечера ужин", "2025-09-03 19:00", "ужин"},
+		{"послезавтра отчёт", "2025-09-05 09:00", "отчёт"},
+		{"сегодня в 23:59 спать", "2025-09-03 23:59", "спать"},
+		{"tomorrow at 9pm movie", "2025-09-04 21:00", "movie"},
+		{"day after tomorrow dentist", "2025-09-05 09:00", "dentist"},
+		{"18:00 tomorrow gym", "2025-09-04 18:00", "gym"},
+		{"в 9 завтра зарядка", "2025-09-04 09:00", "зарядка"},
+		{"через полчаса чай", "2025-09-03 15:00", "чай"},
+		{"через час обед", "2025-09-03 15:30", "обед"},
+		{"in 2h deploy", "2025-09-03 16:30", "deploy"},
+		{"in an hour", "2025-09-03 15:30", ""},
+		{"через два дня в 10:00 врач", "2025-09-05 10:00", "врач"},
+		{"через 1 неделю отпуск", "2025-09-10 14:30", "отпуск"},
+		{"в пятницу", "2025-09-05 09:00", ""},
+		{"в пятницу в 18:30 кино", "2025-09-05 18:30", "кино"},
+		{"в среду созвон", "2025-09-10 09:00", "созвон"},
+		{"Next Monday 08:15 Planning", "2025-09-08 08:15", "Planning"},
+		{"в 12 ночи", "2025-09-04 00:00", ""},
+		{"at 10.30 standup", "2025-09-04 10:30", "standup"},
+		{"в 15 часов", "2025-09-03 15:00", ""},
+		{"в 3 часа дня обед", "2025-09-03 15:00", "обед"},
+		// hour has passed today, so time is tomorrow
+		{"в 9 планёрка", "2025-09-04 09:00", "планёрка"},
+		{"14:30 сейчас", "2025-09-04 14:30", "сейчас"},
+		{"14:31 почти сейчас", "2025-09-03 14:31", "почти сейчас"},
+		// bare number is not a time
+		{"10 яблок купить", "", ""},
+		{"2 часа ночи", "2025-09-04 02:00", ""},
+		// strict format is left for fallback of caller
+		{"2025-09-01 10:00 текст", "", ""},
+		{"купить хлеб", "", ""},
+		{"через", "", ""},
+		{"in five apples", "", ""},
+		{"", "", ""},
+	}
+
+	for _, zone := range []string{"Europe/Moscow", "America/New_York"} {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2025, 9, 3, 14, 30, 0, 0, loc)
+
+		for _, tt := range tests {
+			t.Run(zone+"/"+tt.in, func(t *testing.T) {
+				got, rest, err := Parse(tt.in, now)
+				if tt.want == "" {
+					if !errors.Is(err, ErrNoDate) {
+						t.Fatalf("Parse(%q) = %s, %q, %v, want ErrNoDate", tt.in, got, rest, err)
+					}
+					if rest != tt.in {
+						t.Errorf("rest = %q, want input %q", rest, tt.in)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("Parse(%q): %v", tt.in, err)
+				}
+				if got.Location() != loc {
+					t.Errorf("location = %s, want %s", got.Location(), loc)
+				}
+				if s := got.Format(layout); s != tt.want {
+					t.Errorf("Parse(%q) = %s, want %s", tt.in, s, tt.want)
+				}
+				if rest != tt.rest {
+					t.Errorf("rest = %q, want %q", rest, tt.rest)
+				}
+			})
+		}
+	}
+}
+
+// TestParseDST checks that days keep wall clock time across DST change while hours are absolute.
+func TestParseDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// clocks go back on 2025-11-02 at 02:00
+	now := time.Date(2025, 11, 1, 10, 0, 0, 0, loc)
+
+	tests := []struct {
+		in, want string
+	}{
+		{"через 2 дня в 10:00", "2025-11-03 10:00 EST"},
+		{"завтра в 10", "2025-11-02 10:00 EST"},
+		{"через 48 часов", "2025-11-03 09:00 EST"},
+	}
+
+	for _, tt := range tests {
+		got, _, err := Parse(tt.in, now)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.in, err)
+		}
+		if s := got.Format(layout + " MST"); s != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, s, tt.want)
+		}
+	}
+}
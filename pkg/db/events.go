@@ -18,13 +18,25 @@ type EventsRepo struct {
 // NewEventsRepo returns new repository
 func NewEventsRepo(db orm.DB) EventsRepo {
 	return EventsRepo{
-		db:      db,
-		filters: map[string][]Filter{},
+		db: db,
+		filters: map[string][]Filter{
+			Tables.Event.Name:        {},
+			Tables.Notification.Name: {},
+			Tables.Outbox.Name:       {},
+		},
 		sort: map[string][]SortField{
-			Tables.Event.Name: {{Column: Columns.Event.CreatedAt, Direction: SortDesc}},
+			Tables.Event.Name:        {{Column: Columns.Event.CreatedAt, Direction: SortDesc}},
+			Tables.User.Name:         {{Column: Columns.User.CreatedAt, Direction: SortDesc}},
+			Tables.Notification.Name: {{Column: Columns.Notification.CreatedAt, Direction: SortDesc}},
+			Tables.Dialog.Name:       {{Column: Columns.Dialog.UpdatedAt, Direction: SortDesc}},
+			Tables.Outbox.Name:       {{Column: Columns.Outbox.CreatedAt, Direction: SortDesc}},
 		},
 		join: map[string][]string{
-			Tables.Event.Name: {TableColumns},
+			Tables.Event.Name:        {TableColumns},
+			Tables.User.Name:         {TableColumns},
+			Tables.Notification.Name: {TableColumns, Columns.Notification.Event},
+			Tables.Dialog.Name:       {TableColumns},
+			Tables.Outbox.Name:       {TableColumns, Columns.Outbox.Event},
 		},
 	}
 }
@@ -102,11 +114,36 @@ func (er EventsRepo) AddEvent(ctx context.Context, event *Event, ops ...OpFunc)
 	return event, err
 }
 
+// AddUserEvent adds Event to DB assigning next per-user sequence number to UserEventID.
+func (er EventsRepo) AddUserEvent(ctx context.Context, event *Event) (*Event, error) {
+	id, err := er.NextUserEventID(ctx, event.UserTgID)
+	if err != nil {
+		return nil, err
+	}
+
+	event.UserEventID = id
+	return er.AddEvent(ctx, event)
+}
+
+// NextUserEventID increments per-user counter of event_counters and returns its new value.
+// Concurrent calls for one user wait for the counter row lock, so they never get the same value.
+// Counter of a new user starts after the greatest UserEventID of the user's events.
+func (er EventsRepo) NextUserEventID(ctx context.Context, userTgID int64) (int, error) {
+	var id int
+	_, err := er.db.QueryOneContext(ctx, pg.Scan(&id), `INSERT INTO event_counters AS c (?0, "lastUserEventId")
+		VALUES (?1, (SELECT COALESCE(MAX(?2), 0) + 1 FROM ?3 WHERE ?0 = ?1))
+		ON CONFLICT (?0) DO UPDATE SET "lastUserEventId" = c."lastUserEventId" + 1
+		RETURNING "lastUserEventId"`,
+		pg.Ident(Columns.Event.UserTgID), userTgID, pg.Ident(Columns.Event.UserEventID), pg.Ident(Tables.Event.Name))
+
+	return id, err
+}
+
 // UpdateEvent updates Event in DB.
 func (er EventsRepo) UpdateEvent(ctx context.Context, event *Event, ops ...OpFunc) (bool, error) {
 	q := er.db.ModelContext(ctx, event).WherePK()
 	if len(ops) == 0 {
-		q = q.ExcludeColumn(Columns.Event.ID, Columns.Event.CreatedAt)
+		q = q.ExcludeColumn(Columns.Event.ID, Columns.Event.UserEventID, Columns.Event.CreatedAt)
 	}
 	applyOps(q, ops...)
 	res, err := q.Update()
@@ -128,3 +165,339 @@ func (er EventsRepo) DeleteEvent(ctx context.Context, id int) (deleted bool, err
 
 	return res.RowsAffected() > 0, err
 }
+
+/*** User ***/
+
+// FullUser returns full joins with all columns
+func (er EventsRepo) FullUser() OpFunc {
+	return WithColumns(er.join[Tables.User.Name]...)
+}
+
+// DefaultUserSort returns default sort.
+func (er EventsRepo) DefaultUserSort() OpFunc {
+	return WithSort(er.sort[Tables.User.Name]...)
+}
+
+// UserByID is a function that returns User by ID(s) or nil.
+func (er EventsRepo) UserByID(ctx context.Context, id int64, ops ...OpFunc) (*User, error) {
+	return er.OneUser(ctx, &UserSearch{ID: &id}, ops...)
+}
+
+// OneUser is a function that returns one User by filters. It could return pg.ErrMultiRows.
+func (er EventsRepo) OneUser(ctx context.Context, search *UserSearch, ops ...OpFunc) (*User, error) {
+	obj := &User{}
+	err := buildQuery(ctx, er.db, obj, search, er.filters[Tables.User.Name], PagerTwo, ops...).Select()
+
+	if errors.Is(err, pg.ErrMultiRows) {
+		return nil, err
+	} else if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+
+	return obj, err
+}
+
+// UsersByFilters returns User list.
+func (er EventsRepo) UsersByFilters(ctx context.Context, search *UserSearch, pager Pager, ops ...OpFunc) (users []User, err error) {
+	err = buildQuery(ctx, er.db, &users, search, er.filters[Tables.User.Name], pager, ops...).Select()
+	return
+}
+
+// CountUsers returns count
+func (er EventsRepo) CountUsers(ctx context.Context, search *UserSearch, ops ...OpFunc) (int, error) {
+	return buildQuery(ctx, er.db, &User{}, search, er.filters[Tables.User.Name], PagerOne, ops...).Count()
+}
+
+// AddUser adds User to DB.
+func (er EventsRepo) AddUser(ctx context.Context, user *User, ops ...OpFunc) (*User, error) {
+	q := er.db.ModelContext(ctx, user)
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.User.CreatedAt)
+	}
+	applyOps(q, ops...)
+	_, err := q.Insert()
+
+	return user, err
+}
+
+// UpdateUser updates User in DB.
+func (er EventsRepo) UpdateUser(ctx context.Context, user *User, ops ...OpFunc) (bool, error) {
+	q := er.db.ModelContext(ctx, user).WherePK()
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.User.ID, Columns.User.CreatedAt)
+	}
+	applyOps(q, ops...)
+	res, err := q.Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+// DeleteUser deletes User from DB.
+func (er EventsRepo) DeleteUser(ctx context.Context, id int64) (deleted bool, err error) {
+	user := &User{ID: id}
+
+	res, err := er.db.ModelContext(ctx, user).WherePK().Delete()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+/*** Notification ***/
+
+// FullNotification returns full joins with all columns
+func (er EventsRepo) FullNotification() OpFunc {
+	return WithColumns(er.join[Tables.Notification.Name]...)
+}
+
+// DefaultNotificationSort returns default sort.
+func (er EventsRepo) DefaultNotificationSort() OpFunc {
+	return WithSort(er.sort[Tables.Notification.Name]...)
+}
+
+// NotificationByID is a function that returns Notification by ID(s) or nil.
+func (er EventsRepo) NotificationByID(ctx context.Context, id int, ops ...OpFunc) (*Notification, error) {
+	return er.OneNotification(ctx, &NotificationSearch{ID: &id}, ops...)
+}
+
+// OneNotification is a function that returns one Notification by filters. It could return pg.ErrMultiRows.
+func (er EventsRepo) OneNotification(ctx context.Context, search *NotificationSearch, ops ...OpFunc) (*Notification, error) {
+	obj := &Notification{}
+	err := buildQuery(ctx, er.db, obj, search, er.filters[Tables.Notification.Name], PagerTwo, ops...).Select()
+
+	if errors.Is(err, pg.ErrMultiRows) {
+		return nil, err
+	} else if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+
+	return obj, err
+}
+
+// NotificationsByFilters returns Notification list.
+func (er EventsRepo) NotificationsByFilters(ctx context.Context, search *NotificationSearch, pager Pager, ops ...OpFunc) (notifications []Notification, err error) {
+	err = buildQuery(ctx, er.db, &notifications, search, er.filters[Tables.Notification.Name], pager, ops...).Select()
+	return
+}
+
+// CountNotifications returns count
+func (er EventsRepo) CountNotifications(ctx context.Context, search *NotificationSearch, ops ...OpFunc) (int, error) {
+	return buildQuery(ctx, er.db, &Notification{}, search, er.filters[Tables.Notification.Name], PagerOne, ops...).Count()
+}
+
+// AddNotification adds Notification to DB.
+func (er EventsRepo) AddNotification(ctx context.Context, notification *Notification, ops ...OpFunc) (*Notification, error) {
+	q := er.db.ModelContext(ctx, notification)
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Notification.CreatedAt)
+	}
+	applyOps(q, ops...)
+	_, err := q.Insert()
+
+	return notification, err
+}
+
+// UpdateNotification updates Notification in DB.
+func (er EventsRepo) UpdateNotification(ctx context.Context, notification *Notification, ops ...OpFunc) (bool, error) {
+	q := er.db.ModelContext(ctx, notification).WherePK()
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Notification.ID, Columns.Notification.CreatedAt)
+	}
+	applyOps(q, ops...)
+	res, err := q.Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+// DeleteNotification deletes Notification from DB.
+func (er EventsRepo) DeleteNotification(ctx context.Context, id int) (deleted bool, err error) {
+	notification := &Notification{ID: id}
+
+	res, err := er.db.ModelContext(ctx, notification).WherePK().Delete()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+/*** Dialog ***/
+
+// FullDialog returns full joins with all columns
+func (er EventsRepo) FullDialog() OpFunc {
+	return WithColumns(er.join[Tables.Dialog.Name]...)
+}
+
+// DefaultDialogSort returns default sort.
+func (er EventsRepo) DefaultDialogSort() OpFunc {
+	return WithSort(er.sort[Tables.Dialog.Name]...)
+}
+
+// DialogByID is a function that returns Dialog by ID(s) or nil.
+func (er EventsRepo) DialogByID(ctx context.Context, id int64, ops ...OpFunc) (*Dialog, error) {
+	return er.OneDialog(ctx, &DialogSearch{ID: &id}, ops...)
+}
+
+// OneDialog is a function that returns one Dialog by filters. It could return pg.ErrMultiRows.
+func (er EventsRepo) OneDialog(ctx context.Context, search *DialogSearch, ops ...OpFunc) (*Dialog, error) {
+	obj := &Dialog{}
+	err := buildQuery(ctx, er.db, obj, search, er.filters[Tables.Dialog.Name], PagerTwo, ops...).Select()
+
+	if errors.Is(err, pg.ErrMultiRows) {
+		return nil, err
+	} else if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+
+	return obj, err
+}
+
+// DialogsByFilters returns Dialog list.
+func (er EventsRepo) DialogsByFilters(ctx context.Context, search *DialogSearch, pager Pager, ops ...OpFunc) (dialogs []Dialog, err error) {
+	err = buildQuery(ctx, er.db, &dialogs, search, er.filters[Tables.Dialog.Name], pager, ops...).Select()
+	return
+}
+
+// CountDialogs returns count
+func (er EventsRepo) CountDialogs(ctx context.Context, search *DialogSearch, ops ...OpFunc) (int, error) {
+	return buildQuery(ctx, er.db, &Dialog{}, search, er.filters[Tables.Dialog.Name], PagerOne, ops...).Count()
+}
+
+// AddDialog adds Dialog to DB.
+func (er EventsRepo) AddDialog(ctx context.Context, dialog *Dialog, ops ...OpFunc) (*Dialog, error) {
+	q := er.db.ModelContext(ctx, dialog)
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Dialog.UpdatedAt)
+	}
+	applyOps(q, ops...)
+	_, err := q.Insert()
+
+	return dialog, err
+}
+
+// SaveDialog inserts Dialog or replaces existing Dialog of the same user.
+func (er EventsRepo) SaveDialog(ctx context.Context, dialog *Dialog) (*Dialog, error) {
+	return er.AddDialog(ctx, dialog, WithoutColumns(Columns.Dialog.UpdatedAt), func(q *orm.Query) {
+		q.OnConflict("(?) DO UPDATE", pg.Ident(Columns.Dialog.ID)).
+			Set("? = EXCLUDED.?", pg.Ident(Columns.Dialog.Step), pg.Ident(Columns.Dialog.Step)).
+			Set("? = EXCLUDED.?", pg.Ident(Columns.Dialog.Message), pg.Ident(Columns.Dialog.Message)).
+			Set("? = EXCLUDED.?", pg.Ident(Columns.Dialog.SendAt), pg.Ident(Columns.Dialog.SendAt)).
+			Set("? = EXCLUDED.?", pg.Ident(Columns.Dialog.CreatorTgID), pg.Ident(Columns.Dialog.CreatorTgID)).
+			Set("? = NOW()", pg.Ident(Columns.Dialog.UpdatedAt))
+	})
+}
+
+// UpdateDialog updates Dialog in DB.
+func (er EventsRepo) UpdateDialog(ctx context.Context, dialog *Dialog, ops ...OpFunc) (bool, error) {
+	q := er.db.ModelContext(ctx, dialog).WherePK()
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Dialog.ID, Columns.Dialog.UpdatedAt)
+	}
+	applyOps(q, ops...)
+	res, err := q.Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+// DeleteDialog deletes Dialog from DB.
+func (er EventsRepo) DeleteDialog(ctx context.Context, id int64) (deleted bool, err error) {
+	dialog := &Dialog{ID: id}
+
+	res, err := er.db.ModelContext(ctx, dialog).WherePK().Delete()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+/*** Outbox ***/
+
+// FullOutbox returns full joins with all columns
+func (er EventsRepo) FullOutbox() OpFunc {
+	return WithColumns(er.join[Tables.Outbox.Name]...)
+}
+
+// DefaultOutboxSort returns default sort.
+func (er EventsRepo) DefaultOutboxSort() OpFunc {
+	return WithSort(er.sort[Tables.Outbox.Name]...)
+}
+
+// OutboxByID is a function that returns Outbox by ID(s) or nil.
+func (er EventsRepo) OutboxByID(ctx context.Context, id int, ops ...OpFunc) (*Outbox, error) {
+	return er.OneOutbox(ctx, &OutboxSearch{ID: &id}, ops...)
+}
+
+// OneOutbox is a function that returns one Outbox by filters. It could return pg.ErrMultiRows.
+func (er EventsRepo) OneOutbox(ctx context.Context, search *OutboxSearch, ops ...OpFunc) (*Outbox, error) {
+	obj := &Outbox{}
+	err := buildQuery(ctx, er.db, obj, search, er.filters[Tables.Outbox.Name], PagerTwo, ops...).Select()
+
+	if errors.Is(err, pg.ErrMultiRows) {
+		return nil, err
+	} else if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+
+	return obj, err
+}
+
+// OutboxesByFilters returns Outbox list.
+func (er EventsRepo) OutboxesByFilters(ctx context.Context, search *OutboxSearch, pager Pager, ops ...OpFunc) (outboxes []Outbox, err error) {
+	err = buildQuery(ctx, er.db, &outboxes, search, er.filters[Tables.Outbox.Name], pager, ops...).Select()
+	return
+}
+
+// CountOutboxes returns count
+func (er EventsRepo) CountOutboxes(ctx context.Context, search *OutboxSearch, ops ...OpFunc) (int, error) {
+	return buildQuery(ctx, er.db, &Outbox{}, search, er.filters[Tables.Outbox.Name], PagerOne, ops...).Count()
+}
+
+// AddOutbox adds Outbox to DB.
+func (er EventsRepo) AddOutbox(ctx context.Context, outbox *Outbox, ops ...OpFunc) (*Outbox, error) {
+	q := er.db.ModelContext(ctx, outbox)
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Outbox.CreatedAt)
+	}
+	applyOps(q, ops...)
+	_, err := q.Insert()
+
+	return outbox, err
+}
+
+// UpdateOutbox updates Outbox in DB.
+func (er EventsRepo) UpdateOutbox(ctx context.Context, outbox *Outbox, ops ...OpFunc) (bool, error) {
+	q := er.db.ModelContext(ctx, outbox).WherePK()
+	if len(ops) == 0 {
+		q = q.ExcludeColumn(Columns.Outbox.ID, Columns.Outbox.CreatedAt)
+	}
+	applyOps(q, ops...)
+	res, err := q.Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
+
+// DeleteOutbox deletes Outbox from DB.
+func (er EventsRepo) DeleteOutbox(ctx context.Context, id int) (deleted bool, err error) {
+	outbox := &Outbox{ID: id}
+
+	res, err := er.db.ModelContext(ctx, outbox).WherePK().Delete()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, err
+}
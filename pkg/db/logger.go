@@ -2,12 +2,25 @@ package db
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Duration of database queries.",
+	Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"status"})
+
+func init() {
+	prometheus.MustRegister(queryDuration)
+}
+
+// QueryLogger records durations of queries to db_query_duration_seconds metric and prints queries if logger is set.
 type QueryLogger struct {
 	logger *log.Logger
 }
@@ -22,11 +35,6 @@ func (ql QueryLogger) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (co
 }
 
 func (ql QueryLogger) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
-	query, err := event.FormattedQuery()
-	if err != nil {
-		ql.logger.Printf("formatted query err=%s", err)
-	}
-
 	var since time.Duration
 	if event.Stash != nil {
 		if v, ok := event.Stash["startedAt"]; ok {
@@ -36,10 +44,26 @@ func (ql QueryLogger) AfterQuery(ctx context.Context, event *pg.QueryEvent) erro
 		}
 	}
 
+	status := "ok"
+	if event.Err != nil && !errors.Is(event.Err, pg.ErrNoRows) {
+		status = "error"
+	}
+	queryDuration.WithLabelValues(status).Observe(since.Seconds())
+
+	if ql.logger == nil {
+		return nil
+	}
+
+	query, err := event.FormattedQuery()
+	if err != nil {
+		ql.logger.Printf("formatted query err=%s", err)
+	}
+
 	ql.logger.Printf("query=%s duration=%v", query, since)
 	return nil
 }
 
+// NewQueryLogger returns query hook, logger may be nil to record only metrics.
 func NewQueryLogger(logger *log.Logger) QueryLogger {
 	return QueryLogger{logger: logger}
 }
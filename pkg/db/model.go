@@ -10,24 +10,111 @@ import (
 
 var Columns = struct {
 	Event struct {
-		ID, UserTgID, Message, SendAt, CreatedAt string
+		ID, UserTgID, UserEventID, Message, SendAt, Recurrence, StatusID, SentAt, CreatedAt, Tags, CreatorTgID, Mentions string
+	}
+	User struct {
+		ID, Timezone, CreatedAt string
+	}
+	Notification struct {
+		ID, EventID, OffsetMinutes, SendAt, StatusID, SentAt, CreatedAt string
+
+		Event string
+	}
+	Dialog struct {
+		ID, Step, Message, SendAt, UpdatedAt, CreatorTgID string
+	}
+	Outbox struct {
+		ID, EventID, NotificationID, UserTgID, Message, SendAt, StatusID, Attempts, NextAttemptAt, LastError, SentAt, CreatedAt string
+
+		Event string
 	}
 }{
 	Event: struct {
-		ID, UserTgID, Message, SendAt, CreatedAt string
+		ID, UserTgID, UserEventID, Message, SendAt, Recurrence, StatusID, SentAt, CreatedAt, Tags, CreatorTgID, Mentions string
+	}{
+		ID:          "eventId",
+		UserTgID:    "userTgId",
+		UserEventID: "userEventId",
+		Message:     "message",
+		SendAt:      "sendAt",
+		Recurrence:  "recurrence",
+		StatusID:    "statusId",
+		SentAt:      "sentAt",
+		CreatedAt:   "createdAt",
+		Tags:        "tags",
+		CreatorTgID: "creatorTgId",
+		Mentions:    "mentions",
+	},
+	User: struct {
+		ID, Timezone, CreatedAt string
 	}{
-		ID:        "eventId",
-		UserTgID:  "userTgId",
-		Message:   "message",
-		SendAt:    "sendAt",
+		ID:        "userTgId",
+		Timezone:  "timezone",
 		CreatedAt: "createdAt",
 	},
+	Notification: struct {
+		ID, EventID, OffsetMinutes, SendAt, StatusID, SentAt, CreatedAt string
+
+		Event string
+	}{
+		ID:            "notificationId",
+		EventID:       "eventId",
+		OffsetMinutes: "offsetMinutes",
+		SendAt:        "sendAt",
+		StatusID:      "statusId",
+		SentAt:        "sentAt",
+		CreatedAt:     "createdAt",
+
+		Event: "Event",
+	},
+	Dialog: struct {
+		ID, Step, Message, SendAt, UpdatedAt, CreatorTgID string
+	}{
+		ID:          "userTgId",
+		Step:        "step",
+		Message:     "message",
+		SendAt:      "sendAt",
+		UpdatedAt:   "updatedAt",
+		CreatorTgID: "creatorTgId",
+	},
+	Outbox: struct {
+		ID, EventID, NotificationID, UserTgID, Message, SendAt, StatusID, Attempts, NextAttemptAt, LastError, SentAt, CreatedAt string
+
+		Event string
+	}{
+		ID:             "outboxId",
+		EventID:        "eventId",
+		NotificationID: "notificationId",
+		UserTgID:       "userTgId",
+		Message:        "message",
+		SendAt:         "sendAt",
+		StatusID:       "statusId",
+		Attempts:       "attempts",
+		NextAttemptAt:  "nextAttemptAt",
+		LastError:      "lastError",
+		SentAt:         "sentAt",
+		CreatedAt:      "createdAt",
+
+		Event: "Event",
+	},
 }
 
 var Tables = struct {
 	Event struct {
 		Name, Alias string
 	}
+	User struct {
+		Name, Alias string
+	}
+	Notification struct {
+		Name, Alias string
+	}
+	Dialog struct {
+		Name, Alias string
+	}
+	Outbox struct {
+		Name, Alias string
+	}
 }{
 	Event: struct {
 		Name, Alias string
@@ -35,14 +122,97 @@ var Tables = struct {
 		Name:  "events",
 		Alias: "t",
 	},
+	User: struct {
+		Name, Alias string
+	}{
+		Name:  "users",
+		Alias: "t",
+	},
+	Notification: struct {
+		Name, Alias string
+	}{
+		Name:  "notifications",
+		Alias: "t",
+	},
+	Dialog: struct {
+		Name, Alias string
+	}{
+		Name:  "dialogs",
+		Alias: "t",
+	},
+	Outbox: struct {
+		Name, Alias string
+	}{
+		Name:  "outbox",
+		Alias: "t",
+	},
 }
 
 type Event struct {
 	tableName struct{} `pg:"events,alias:t,discard_unknown_columns"`
 
-	ID        int       `pg:"eventId,pk"`
-	UserTgID  int64     `pg:"userTgId,use_zero"`
-	Message   string    `pg:"message,use_zero"`
-	SendAt    time.Time `pg:"sendAt,use_zero"`
+	ID          int        `pg:"eventId,pk"`
+	UserTgID    int64      `pg:"userTgId,use_zero"`
+	UserEventID int        `pg:"userEventId,use_zero"`
+	Message     string     `pg:"message,use_zero"`
+	SendAt      time.Time  `pg:"sendAt,use_zero"`
+	Recurrence  *string    `pg:"recurrence"`
+	StatusID    int        `pg:"statusId,use_zero"`
+	SentAt      *time.Time `pg:"sentAt"`
+	CreatedAt   time.Time  `pg:"createdAt,use_zero"`
+	Tags        []string   `pg:"tags,array"`
+	CreatorTgID *int64     `pg:"creatorTgId"`
+	Mentions    []string   `pg:"mentions,array"`
+}
+
+type User struct {
+	tableName struct{} `pg:"users,alias:t,discard_unknown_columns"`
+
+	ID        int64     `pg:"userTgId,pk"`
+	Timezone  string    `pg:"timezone,use_zero"`
 	CreatedAt time.Time `pg:"createdAt,use_zero"`
 }
+
+type Notification struct {
+	tableName struct{} `pg:"notifications,alias:t,discard_unknown_columns"`
+
+	ID            int        `pg:"notificationId,pk"`
+	EventID       int        `pg:"eventId,use_zero"`
+	OffsetMinutes int        `pg:"offsetMinutes,use_zero"`
+	SendAt        time.Time  `pg:"sendAt,use_zero"`
+	StatusID      int        `pg:"statusId,use_zero"`
+	SentAt        *time.Time `pg:"sentAt"`
+	CreatedAt     time.Time  `pg:"createdAt,use_zero"`
+
+	Event *Event `pg:"fk:eventId,rel:has-one"`
+}
+
+type Dialog struct {
+	tableName struct{} `pg:"dialogs,alias:t,discard_unknown_columns"`
+
+	ID          int64      `pg:"userTgId,pk"`
+	Step        string     `pg:"step,use_zero"`
+	Message     *string    `pg:"message"`
+	SendAt      *time.Time `pg:"sendAt"`
+	UpdatedAt   time.Time  `pg:"updatedAt,use_zero"`
+	CreatorTgID *int64     `pg:"creatorTgId"`
+}
+
+type Outbox struct {
+	tableName struct{} `pg:"outbox,alias:t,discard_unknown_columns"`
+
+	ID             int        `pg:"outboxId,pk"`
+	EventID        int        `pg:"eventId,use_zero"`
+	NotificationID *int       `pg:"notificationId"`
+	UserTgID       int64      `pg:"userTgId,use_zero"`
+	Message        string     `pg:"message,use_zero"`
+	SendAt         time.Time  `pg:"sendAt,use_zero"`
+	StatusID       int        `pg:"statusId,use_zero"`
+	Attempts       int        `pg:"attempts,use_zero"`
+	NextAttemptAt  time.Time  `pg:"nextAttemptAt,use_zero"`
+	LastError      *string    `pg:"lastError"`
+	SentAt         *time.Time `pg:"sentAt"`
+	CreatedAt      time.Time  `pg:"createdAt,use_zero"`
+
+	Event *Event `pg:"fk:eventId,rel:has-one"`
+}
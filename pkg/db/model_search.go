@@ -57,11 +57,21 @@ type EventSearch struct {
 
 	ID           *int
 	UserTgID     *int64
+	UserEventID  *int
 	Message      *string
 	SendAt       *time.Time
+	Recurrence   *string
+	StatusID     *int
+	SentAt       *time.Time
 	CreatedAt    *time.Time
 	IDs          []int
+	StatusIDs    []int
 	MessageILike *string
+	SendAtFrom   *time.Time
+	SendAtTo     *time.Time
+	Tag          *string
+	TagsAny      []string
+	CreatorTgID  *int64
 }
 
 func (es *EventSearch) Apply(query *orm.Query) *orm.Query {
@@ -74,21 +84,51 @@ func (es *EventSearch) Apply(query *orm.Query) *orm.Query {
 	if es.UserTgID != nil {
 		es.where(query, Tables.Event.Alias, Columns.Event.UserTgID, es.UserTgID)
 	}
+	if es.UserEventID != nil {
+		es.where(query, Tables.Event.Alias, Columns.Event.UserEventID, es.UserEventID)
+	}
 	if es.Message != nil {
 		es.where(query, Tables.Event.Alias, Columns.Event.Message, es.Message)
 	}
 	if es.SendAt != nil {
 		es.where(query, Tables.Event.Alias, Columns.Event.SendAt, es.SendAt)
 	}
+	if es.Recurrence != nil {
+		es.where(query, Tables.Event.Alias, Columns.Event.Recurrence, es.Recurrence)
+	}
+	if es.StatusID != nil {
+		es.where(query, Tables.Event.Alias, Columns.Event.StatusID, es.StatusID)
+	}
+	if es.SentAt != nil {
+		es.where(query, Tables.Event.Alias, Columns.Event.SentAt, es.SentAt)
+	}
 	if es.CreatedAt != nil {
 		es.where(query, Tables.Event.Alias, Columns.Event.CreatedAt, es.CreatedAt)
 	}
 	if len(es.IDs) > 0 {
 		Filter{Columns.Event.ID, es.IDs, SearchTypeArray, false}.Apply(query)
 	}
+	if len(es.StatusIDs) > 0 {
+		Filter{Columns.Event.StatusID, es.StatusIDs, SearchTypeArray, false}.Apply(query)
+	}
 	if es.MessageILike != nil {
 		Filter{Columns.Event.Message, *es.MessageILike, SearchTypeILike, false}.Apply(query)
 	}
+	if es.SendAtFrom != nil {
+		Filter{Columns.Event.SendAt, *es.SendAtFrom, SearchTypeGE, false}.Apply(query)
+	}
+	if es.SendAtTo != nil {
+		Filter{Columns.Event.SendAt, *es.SendAtTo, SearchTypeLess, false}.Apply(query)
+	}
+	if es.CreatorTgID != nil {
+		es.where(query, Tables.Event.Alias, Columns.Event.CreatorTgID, es.CreatorTgID)
+	}
+	if es.Tag != nil {
+		Filter{Columns.Event.Tags, *es.Tag, SearchTypeArrayContains, false}.Apply(query)
+	}
+	if len(es.TagsAny) > 0 {
+		Filter{Columns.Event.Tags, es.TagsAny, SearchTypeArrayIntersect, false}.Apply(query)
+	}
 
 	es.apply(query)
 
@@ -103,3 +143,243 @@ func (es *EventSearch) Q() applier {
 		return es.Apply(query), nil
 	}
 }
+
+type UserSearch struct {
+	search
+
+	ID        *int64
+	Timezone  *string
+	CreatedAt *time.Time
+	IDs       []int64
+}
+
+func (us *UserSearch) Apply(query *orm.Query) *orm.Query {
+	if us == nil {
+		return query
+	}
+	if us.ID != nil {
+		us.where(query, Tables.User.Alias, Columns.User.ID, us.ID)
+	}
+	if us.Timezone != nil {
+		us.where(query, Tables.User.Alias, Columns.User.Timezone, us.Timezone)
+	}
+	if us.CreatedAt != nil {
+		us.where(query, Tables.User.Alias, Columns.User.CreatedAt, us.CreatedAt)
+	}
+	if len(us.IDs) > 0 {
+		Filter{Columns.User.ID, us.IDs, SearchTypeArray, false}.Apply(query)
+	}
+
+	us.apply(query)
+
+	return query
+}
+
+func (us *UserSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if us == nil {
+			return query, nil
+		}
+		return us.Apply(query), nil
+	}
+}
+
+type NotificationSearch struct {
+	search
+
+	ID            *int
+	EventID       *int
+	OffsetMinutes *int
+	SendAt        *time.Time
+	StatusID      *int
+	SentAt        *time.Time
+	CreatedAt     *time.Time
+	IDs           []int
+	EventIDs      []int
+	SendAtTo      *time.Time
+}
+
+func (ns *NotificationSearch) Apply(query *orm.Query) *orm.Query {
+	if ns == nil {
+		return query
+	}
+	if ns.ID != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.ID, ns.ID)
+	}
+	if ns.EventID != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.EventID, ns.EventID)
+	}
+	if ns.OffsetMinutes != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.OffsetMinutes, ns.OffsetMinutes)
+	}
+	if ns.SendAt != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.SendAt, ns.SendAt)
+	}
+	if ns.StatusID != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.StatusID, ns.StatusID)
+	}
+	if ns.SentAt != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.SentAt, ns.SentAt)
+	}
+	if ns.CreatedAt != nil {
+		ns.where(query, Tables.Notification.Alias, Columns.Notification.CreatedAt, ns.CreatedAt)
+	}
+	if len(ns.IDs) > 0 {
+		Filter{Columns.Notification.ID, ns.IDs, SearchTypeArray, false}.Apply(query)
+	}
+	if len(ns.EventIDs) > 0 {
+		Filter{Columns.Notification.EventID, ns.EventIDs, SearchTypeArray, false}.Apply(query)
+	}
+	if ns.SendAtTo != nil {
+		Filter{Columns.Notification.SendAt, *ns.SendAtTo, SearchTypeLess, false}.Apply(query)
+	}
+
+	ns.apply(query)
+
+	return query
+}
+
+func (ns *NotificationSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if ns == nil {
+			return query, nil
+		}
+		return ns.Apply(query), nil
+	}
+}
+
+type DialogSearch struct {
+	search
+
+	ID          *int64
+	Step        *string
+	Message     *string
+	SendAt      *time.Time
+	UpdatedAt   *time.Time
+	CreatorTgID *int64
+	IDs         []int64
+}
+
+func (ds *DialogSearch) Apply(query *orm.Query) *orm.Query {
+	if ds == nil {
+		return query
+	}
+	if ds.ID != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.ID, ds.ID)
+	}
+	if ds.Step != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.Step, ds.Step)
+	}
+	if ds.Message != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.Message, ds.Message)
+	}
+	if ds.SendAt != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.SendAt, ds.SendAt)
+	}
+	if ds.UpdatedAt != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.UpdatedAt, ds.UpdatedAt)
+	}
+	if ds.CreatorTgID != nil {
+		ds.where(query, Tables.Dialog.Alias, Columns.Dialog.CreatorTgID, ds.CreatorTgID)
+	}
+	if len(ds.IDs) > 0 {
+		Filter{Columns.Dialog.ID, ds.IDs, SearchTypeArray, false}.Apply(query)
+	}
+
+	ds.apply(query)
+
+	return query
+}
+
+func (ds *DialogSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if ds == nil {
+			return query, nil
+		}
+		return ds.Apply(query), nil
+	}
+}
+
+type OutboxSearch struct {
+	search
+
+	ID              *int
+	EventID         *int
+	NotificationID  *int
+	UserTgID        *int64
+	Message         *string
+	SendAt          *time.Time
+	StatusID        *int
+	Attempts        *int
+	NextAttemptAt   *time.Time
+	LastError       *string
+	SentAt          *time.Time
+	CreatedAt       *time.Time
+	IDs             []int
+	StatusIDs       []int
+	NextAttemptAtTo *time.Time
+}
+
+func (os *OutboxSearch) Apply(query *orm.Query) *orm.Query {
+	if os == nil {
+		return query
+	}
+	if os.ID != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.ID, os.ID)
+	}
+	if os.EventID != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.EventID, os.EventID)
+	}
+	if os.NotificationID != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.NotificationID, os.NotificationID)
+	}
+	if os.UserTgID != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.UserTgID, os.UserTgID)
+	}
+	if os.Message != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.Message, os.Message)
+	}
+	if os.SendAt != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.SendAt, os.SendAt)
+	}
+	if os.StatusID != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.StatusID, os.StatusID)
+	}
+	if os.Attempts != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.Attempts, os.Attempts)
+	}
+	if os.NextAttemptAt != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.NextAttemptAt, os.NextAttemptAt)
+	}
+	if os.LastError != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.LastError, os.LastError)
+	}
+	if os.SentAt != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.SentAt, os.SentAt)
+	}
+	if os.CreatedAt != nil {
+		os.where(query, Tables.Outbox.Alias, Columns.Outbox.CreatedAt, os.CreatedAt)
+	}
+	if len(os.IDs) > 0 {
+		Filter{Columns.Outbox.ID, os.IDs, SearchTypeArray, false}.Apply(query)
+	}
+	if len(os.StatusIDs) > 0 {
+		Filter{Columns.Outbox.StatusID, os.StatusIDs, SearchTypeArray, false}.Apply(query)
+	}
+	if os.NextAttemptAtTo != nil {
+		Filter{Columns.Outbox.NextAttemptAt, *os.NextAttemptAtTo, SearchTypeLess, false}.Apply(query)
+	}
+
+	os.apply(query)
+
+	return query
+}
+
+func (os *OutboxSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if os == nil {
+			return query, nil
+		}
+		return os.Apply(query), nil
+	}
+}
@@ -17,6 +17,14 @@ const (
 	StatusEnabled  = 1
 	StatusDisabled = 2
 	StatusDeleted  = 3
+
+	// event statuses
+	EventStatusPending   = StatusEnabled
+	EventStatusCancelled = StatusDeleted
+	EventStatusSent      = 4
+	EventStatusFailed    = 5
+	// EventStatusSending is set while reminder waits in outbox for successful delivery
+	EventStatusSending = 6
 )
 
 var (
@@ -126,6 +134,14 @@ func WithJoinedIDs(ids []int, tableAlias, column string) OpFunc {
 	}
 }
 
+// ForUpdateSkipLocked adds FOR UPDATE OF t SKIP LOCKED to select query, rows of main table locked
+// by other transactions are skipped. Joined relations are not locked.
+func ForUpdateSkipLocked() OpFunc {
+	return func(query *orm.Query) {
+		query.For("UPDATE OF ? SKIP LOCKED", pg.Ident(TablePrefix))
+	}
+}
+
 // OnConflict adds ON CONFLICT statement to update query
 func OnConflict(s string, params ...interface{}) OpFunc {
 	return func(query *orm.Query) {
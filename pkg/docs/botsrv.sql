@@ -1,13 +1,70 @@
 CREATE TABLE events (
                         "eventId" SERIAL PRIMARY KEY,
                         "userTgId" BIGINT NOT NULL,
+                        "userEventId" INT NOT NULL,
                         "message" TEXT NOT NULL,
                         "sendAt" TIMESTAMPTZ NOT NULL,
-                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+                        "recurrence" TEXT,
+                        "statusId" INT NOT NULL DEFAULT 1,
+                        "sentAt" TIMESTAMPTZ,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        "tags" TEXT[],
+                        "creatorTgId" BIGINT,
+                        "mentions" TEXT[]
 );
 
 CREATE INDEX idx_events_user ON events("userTgId");
+CREATE INDEX idx_events_tags ON events USING GIN ("tags");
 CREATE INDEX idx_events_sendat ON events("sendAt");
+CREATE UNIQUE INDEX idx_events_user_event ON events("userTgId", "userEventId");
+
+CREATE TABLE event_counters (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "lastUserEventId" INT NOT NULL
+);
 
+CREATE TABLE users (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "timezone" TEXT NOT NULL,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE notifications (
+                        "notificationId" SERIAL PRIMARY KEY,
+                        "eventId" INT NOT NULL REFERENCES events("eventId") ON DELETE CASCADE,
+                        "offsetMinutes" INT NOT NULL,
+                        "sendAt" TIMESTAMPTZ NOT NULL,
+                        "statusId" INT NOT NULL DEFAULT 1,
+                        "sentAt" TIMESTAMPTZ,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
 
+CREATE INDEX idx_notifications_event ON notifications("eventId");
+CREATE INDEX idx_notifications_sendat ON notifications("sendAt");
+
+CREATE TABLE dialogs (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "step" TEXT NOT NULL,
+                        "message" TEXT,
+                        "sendAt" TIMESTAMPTZ,
+                        "updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        "creatorTgId" BIGINT
+);
+
+CREATE TABLE outbox (
+                        "outboxId" SERIAL PRIMARY KEY,
+                        "eventId" INT NOT NULL REFERENCES events("eventId") ON DELETE CASCADE,
+                        "notificationId" INT REFERENCES notifications("notificationId") ON DELETE CASCADE,
+                        "userTgId" BIGINT NOT NULL,
+                        "message" TEXT NOT NULL,
+                        "sendAt" TIMESTAMPTZ NOT NULL,
+                        "statusId" INT NOT NULL DEFAULT 1,
+                        "attempts" INT NOT NULL DEFAULT 0,
+                        "nextAttemptAt" TIMESTAMPTZ NOT NULL,
+                        "lastError" TEXT,
+                        "sentAt" TIMESTAMPTZ,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
 
+CREATE INDEX idx_outbox_event ON outbox("eventId");
+CREATE INDEX idx_outbox_next_attempt ON outbox("nextAttemptAt") WHERE "statusId" IN (1, 6);
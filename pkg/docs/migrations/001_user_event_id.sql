@@ -0,0 +1,26 @@
+-- Per-chat event numbers for databases created before "userEventId".
+-- Existing events of every chat are numbered by creation order, counters continue after the last number.
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "userEventId" INT;
+
+UPDATE events e
+SET "userEventId" = n."rowNumber"
+FROM (SELECT "eventId", row_number() OVER (PARTITION BY "userTgId" ORDER BY "eventId") AS "rowNumber"
+      FROM events) n
+WHERE e."eventId" = n."eventId" AND e."userEventId" IS NULL;
+
+ALTER TABLE events ALTER COLUMN "userEventId" SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_user_event ON events("userTgId", "userEventId");
+
+CREATE TABLE IF NOT EXISTS event_counters (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "lastUserEventId" INT NOT NULL
+);
+
+INSERT INTO event_counters ("userTgId", "lastUserEventId")
+SELECT "userTgId", MAX("userEventId") FROM events GROUP BY "userTgId"
+ON CONFLICT ("userTgId") DO UPDATE SET "lastUserEventId" = GREATEST(event_counters."lastUserEventId", EXCLUDED."lastUserEventId");
+
+COMMIT;
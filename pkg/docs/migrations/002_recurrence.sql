@@ -0,0 +1,6 @@
+-- Recurring events, rule is stored in RRULE form, e.g. "FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=9;BYMINUTE=30;TZID=Europe/Moscow".
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "recurrence" TEXT;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Time zones of chats set by /timezone, "userTgId" is chat ID: user ID in private chats, group ID in groups.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS users (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "timezone" TEXT NOT NULL,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- Statuses of events: 1 pending, 3 cancelled, 4 sent, 5 failed, 6 sending.
+-- Delivered and deleted events stay in the table for history, existing events are pending.
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "statusId" INT NOT NULL DEFAULT 1;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "sentAt" TIMESTAMPTZ;
+
+COMMIT;
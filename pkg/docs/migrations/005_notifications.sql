@@ -0,0 +1,17 @@
+-- Advance notifications of events, set by offsets like "-1d,-1h" before event text in /add.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS notifications (
+                        "notificationId" SERIAL PRIMARY KEY,
+                        "eventId" INT NOT NULL REFERENCES events("eventId") ON DELETE CASCADE,
+                        "offsetMinutes" INT NOT NULL,
+                        "sendAt" TIMESTAMPTZ NOT NULL,
+                        "statusId" INT NOT NULL DEFAULT 1,
+                        "sentAt" TIMESTAMPTZ,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_event ON notifications("eventId");
+CREATE INDEX IF NOT EXISTS idx_notifications_sendat ON notifications("sendAt");
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- States of interactive /add dialogs kept when persistent dialogs are enabled, "userTgId" is chat ID.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS dialogs (
+                        "userTgId" BIGINT PRIMARY KEY,
+                        "step" TEXT NOT NULL,
+                        "message" TEXT,
+                        "sendAt" TIMESTAMPTZ,
+                        "updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- Tags of events, set by "#work" words before event text in /add and used by /list filters.
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "tags" TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_events_tags ON events USING GIN ("tags");
+
+COMMIT;
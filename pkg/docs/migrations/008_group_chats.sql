@@ -0,0 +1,10 @@
+-- Events and /add dialogs in group chats: creator of event or dialog and users mentioned in reminder.
+-- Existing events are created by owners of private chats.
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "creatorTgId" BIGINT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS "mentions" TEXT[];
+
+ALTER TABLE dialogs ADD COLUMN IF NOT EXISTS "creatorTgId" BIGINT;
+
+COMMIT;
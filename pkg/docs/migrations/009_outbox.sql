@@ -0,0 +1,25 @@
+-- Outbox of messages sent outside of store transactions.
+-- Messages being sent stay in sending status (6) until "nextAttemptAt", then they are retried,
+-- so the index covers both pending (1) and sending messages.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS outbox (
+                        "outboxId" SERIAL PRIMARY KEY,
+                        "eventId" INT NOT NULL REFERENCES events("eventId") ON DELETE CASCADE,
+                        "notificationId" INT REFERENCES notifications("notificationId") ON DELETE CASCADE,
+                        "userTgId" BIGINT NOT NULL,
+                        "message" TEXT NOT NULL,
+                        "sendAt" TIMESTAMPTZ NOT NULL,
+                        "statusId" INT NOT NULL DEFAULT 1,
+                        "attempts" INT NOT NULL DEFAULT 0,
+                        "nextAttemptAt" TIMESTAMPTZ NOT NULL,
+                        "lastError" TEXT,
+                        "sentAt" TIMESTAMPTZ,
+                        "createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_event ON outbox("eventId");
+DROP INDEX IF EXISTS idx_outbox_next_attempt;
+CREATE INDEX idx_outbox_next_attempt ON outbox("nextAttemptAt") WHERE "statusId" IN (1, 6);
+
+COMMIT;
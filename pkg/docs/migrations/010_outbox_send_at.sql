@@ -0,0 +1,17 @@
+-- Time the queued message is due at, delivery latency is measured from it.
+-- Outbox created before the column was added is filled from notifications and events.
+BEGIN;
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS "sendAt" TIMESTAMPTZ;
+
+UPDATE outbox o SET "sendAt" = n."sendAt"
+FROM notifications n
+WHERE o."sendAt" IS NULL AND o."notificationId" = n."notificationId";
+
+UPDATE outbox o SET "sendAt" = e."sendAt"
+FROM events e
+WHERE o."sendAt" IS NULL AND o."eventId" = e."eventId";
+
+ALTER TABLE outbox ALTER COLUMN "sendAt" SET NOT NULL;
+
+COMMIT;
@@ -8,4 +8,21 @@ type Event struct {
 	ChatID     int64
 	Text       string
 	DateTime   time.Time
+	Recurrence string
+	StatusID   int
+	SentAt     *time.Time
+	Tags       []string
+	// CreatorID is Telegram user who added event, zero for events added before group chats support.
+	CreatorID     int64
+	Mentions      []string
+	Notifications []Notification
+}
+
+// Notification is an advance reminder sent Offset before the event.
+type Notification struct {
+	ID       int
+	EventID  int
+	Offset   time.Duration
+	DateTime time.Time
+	StatusID int
 }
@@ -0,0 +1,317 @@
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Freq string
+
+const (
+	FreqDaily   Freq = "DAILY"
+	FreqWeekly  Freq = "WEEKLY"
+	FreqMonthly Freq = "MONTHLY"
+)
+
+// maxLookahead is the number of days scanned when searching for the next occurrence.
+const maxLookahead = 400
+
+var rruleDays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var specDays = map[string]time.Weekday{
+	"mon": time.Monday, "пн": time.Monday,
+	"tue": time.Tuesday, "вт": time.Tuesday,
+	"wed": time.Wednesday, "ср": time.Wednesday,
+	"thu": time.Thursday, "чт": time.Thursday,
+	"fri": time.Friday, "пт": time.Friday,
+	"sat": time.Saturday, "сб": time.Saturday,
+	"sun": time.Sunday, "вс": time.Sunday,
+}
+
+var shortDayNames = map[time.Weekday]string{
+	time.Monday:    "пн",
+	time.Tuesday:   "вт",
+	time.Wednesday: "ср",
+	time.Thursday:  "чт",
+	time.Friday:    "пт",
+	time.Saturday:  "сб",
+	time.Sunday:    "вс",
+}
+
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// Rule is a subset of RFC 5545 RRULE: FREQ with optional BYDAY or BYMONTHDAY.
+// Occurrences happen at Hour:Minute in Location, so they keep their wall clock time
+// across DST changes and changes of user time zone.
+type Rule struct {
+	Freq       Freq
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Hour       int
+	Minute     int
+	// Location is nil for rules stored without time of day, see At.
+	Location *time.Location
+}
+
+// Parse parses rule stored in RRULE form, e.g. "FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=9;BYMINUTE=30;TZID=Europe/Moscow".
+func Parse(s string) (Rule, error) {
+	var r Rule
+	var hasTime bool
+	for _, part := range strings.Split(strings.TrimSpace(s), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Freq(strings.ToUpper(value))
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleDays[strings.ToUpper(d)]
+				if !ok {
+					return Rule{}, fmt.Errorf("invalid BYDAY value %q", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				md, err := strconv.Atoi(d)
+				if err != nil {
+					return Rule{}, fmt.Errorf("invalid BYMONTHDAY value %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, md)
+			}
+		case "BYHOUR":
+			h, err := strconv.Atoi(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid BYHOUR value %q", value)
+			}
+			r.Hour, hasTime = h, true
+		case "BYMINUTE":
+			m, err := strconv.Atoi(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid BYMINUTE value %q", value)
+			}
+			r.Minute, hasTime = m, true
+		case "TZID":
+			loc, err := time.LoadLocation(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid TZID value %q", value)
+			}
+			r.Location = loc
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if hasTime && r.Location == nil {
+		return Rule{}, fmt.Errorf("BYHOUR and BYMINUTE without TZID")
+	}
+
+	return r, r.validate()
+}
+
+// ParseSpec parses user input from "/add every <spec>": "day", "weekday", "mon,wed" or "1,15".
+func ParseSpec(spec string) (Rule, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	switch spec {
+	case "day", "день":
+		return Rule{Freq: FreqDaily}, nil
+	case "weekday", "будни":
+		return Rule{Freq: FreqWeekly, ByDay: append([]time.Weekday(nil), weekdays...)}, nil
+	}
+
+	items := strings.Split(spec, ",")
+	if _, err := strconv.Atoi(items[0]); err == nil {
+		r := Rule{Freq: FreqMonthly}
+		for _, item := range items {
+			md, err := strconv.Atoi(item)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid day of month %q", item)
+			}
+			r.ByMonthDay = append(r.ByMonthDay, md)
+		}
+		return r, r.validate()
+	}
+
+	r := Rule{Freq: FreqWeekly}
+	for _, item := range items {
+		wd, ok := specDays[item]
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid weekday %q", item)
+		}
+		r.ByDay = append(r.ByDay, wd)
+	}
+	return r, r.validate()
+}
+
+// At returns copy of rule with occurrences at hour:minute in loc.
+func (r Rule) At(hour, minute int, loc *time.Location) Rule {
+	r.Hour, r.Minute, r.Location = hour, minute, loc
+	return r
+}
+
+func (r Rule) validate() error {
+	if r.Hour < 0 || r.Hour > 23 {
+		return fmt.Errorf("invalid hour %d", r.Hour)
+	}
+	if r.Minute < 0 || r.Minute > 59 {
+		return fmt.Errorf("invalid minute %d", r.Minute)
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return fmt.Errorf("weekly rule without BYDAY")
+		}
+	case FreqMonthly:
+		if len(r.ByMonthDay) == 0 {
+			return fmt.Errorf("monthly rule without BYMONTHDAY")
+		}
+		for _, md := range r.ByMonthDay {
+			if md < 1 || md > 31 {
+				return fmt.Errorf("invalid day of month %d", md)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported FREQ %q", r.Freq)
+	}
+
+	return nil
+}
+
+// String returns rule in RRULE form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.sortedDays() {
+			for k, v := range rruleDays {
+				if v == wd {
+					days = append(days, k)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, md := range r.ByMonthDay {
+			days[i] = strconv.Itoa(md)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Location != nil {
+		parts = append(parts,
+			"BYHOUR="+strconv.Itoa(r.Hour),
+			"BYMINUTE="+strconv.Itoa(r.Minute),
+			"TZID="+r.Location.String(),
+		)
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Describe returns human-readable rule description for bot messages.
+func (r Rule) Describe() string {
+	switch r.Freq {
+	case FreqDaily:
+		return "каждый день"
+	case FreqWeekly:
+		if r.isWeekdays() {
+			return "по будням"
+		}
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.sortedDays() {
+			days = append(days, shortDayNames[wd])
+		}
+		return "каждый " + strings.Join(days, ", ")
+	case FreqMonthly:
+		days := make([]string, len(r.ByMonthDay))
+		for i, md := range r.ByMonthDay {
+			days[i] = strconv.Itoa(md)
+		}
+		return "ежемесячно " + strings.Join(days, ", ") + " числа"
+	}
+
+	return r.String()
+}
+
+// Next returns the first occurrence strictly after t at rule's time of day in rule's location,
+// or in t's location if rule has none. Zero time is returned if no occurrence is found.
+func (r Rule) Next(t time.Time) time.Time {
+	if r.Location != nil {
+		t = t.In(r.Location)
+	}
+
+	for d := 0; d <= maxLookahead; d++ {
+		c := time.Date(t.Year(), t.Month(), t.Day()+d, r.Hour, r.Minute, 0, 0, t.Location())
+		if c.Hour() != r.Hour || c.Minute() != r.Minute {
+			// Time of day falls into DST gap, so it is moved forward by the gap as in RFC 5545.
+			_, before := c.Zone()
+			_, after := c.Add(12 * time.Hour).Zone()
+			c = c.Add(time.Duration(after-before) * time.Second)
+		}
+		if c.After(t) && r.matches(c) {
+			return c
+		}
+	}
+
+	return time.Time{}
+}
+
+func (r Rule) matches(t time.Time) bool {
+	switch r.Freq {
+	case FreqDaily:
+		return true
+	case FreqWeekly:
+		for _, wd := range r.ByDay {
+			if t.Weekday() == wd {
+				return true
+			}
+		}
+	case FreqMonthly:
+		for _, md := range r.ByMonthDay {
+			if t.Day() == md {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (r Rule) isWeekdays() bool {
+	days := r.sortedDays()
+	if len(days) != len(weekdays) {
+		return false
+	}
+	for i := range days {
+		if days[i] != weekdays[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedDays returns BYDAY values ordered from Monday to Sunday.
+func (r Rule) sortedDays() []time.Weekday {
+	days := append([]time.Weekday(nil), r.ByDay...)
+	sort.Slice(days, func(i, j int) bool {
+		return (days[i]+6)%7 < (days[j]+6)%7
+	})
+	return days
+}
@@ -0,0 +1,182 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+const layout = "2006-01-02 15:04 MST"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // String() of parsed rule, empty if error is expected
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"freq=daily", "FREQ=DAILY"},
+		{"FREQ=WEEKLY;BYDAY=WE,MO", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15,31", "FREQ=MONTHLY;BYMONTHDAY=1,15,31"},
+		{
+			"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9;BYMINUTE=30;TZID=Europe/Moscow",
+			"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9;BYMINUTE=30;TZID=Europe/Moscow",
+		},
+		{"FREQ=DAILY;TZID=America/New_York", "FREQ=DAILY;BYHOUR=0;BYMINUTE=0;TZID=America/New_York"},
+		{"FREQ=YEARLY", ""},
+		{"FREQ=WEEKLY", ""},
+		{"FREQ=WEEKLY;BYDAY=XX", ""},
+		{"FREQ=MONTHLY", ""},
+		{"FREQ=MONTHLY;BYMONTHDAY=0", ""},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", ""},
+		{"FREQ=DAILY;BYHOUR=24;BYMINUTE=0;TZID=UTC", ""},
+		{"FREQ=DAILY;BYHOUR=9;BYMINUTE=60;TZID=UTC", ""},
+		{"FREQ=DAILY;BYHOUR=9;BYMINUTE=30", ""},
+		{"FREQ=DAILY;TZID=Mars/Olympus", ""},
+		{"FREQ=DAILY;COUNT=3", ""},
+		{"FREQ", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			r, err := Parse(tt.in)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %s, want error", tt.in, r)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string // String() of parsed rule, empty if error is expected
+		describe string
+	}{
+		{"day", "FREQ=DAILY", "каждый день"},
+		{"День", "FREQ=DAILY", "каждый день"},
+		{"weekday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "по будням"},
+		{"будни", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "по будням"},
+		{"fri,mon,wed", "FREQ=WEEKLY;BYDAY=MO,WE,FR", "каждый пн, ср, пт"},
+		{"сб,вс", "FREQ=WEEKLY;BYDAY=SA,SU", "каждый сб, вс"},
+		{"1,15", "FREQ=MONTHLY;BYMONTHDAY=1,15", "ежемесячно 1, 15 числа"},
+		{"31", "FREQ=MONTHLY;BYMONTHDAY=31", "ежемесячно 31 числа"},
+		{"0", "", ""},
+		{"32", "", ""},
+		{"1,x", "", ""},
+		{"mon,funday", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			r, err := ParseSpec(tt.in)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ParseSpec(%q) = %s, want error", tt.in, r)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseSpec(%q): %v", tt.in, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("ParseSpec(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+			if got := r.Describe(); got != tt.describe {
+				t.Errorf("Describe() = %q, want %q", got, tt.describe)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daily := Rule{Freq: FreqDaily}
+	weekdays := Rule{Freq: FreqWeekly, ByDay: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}
+	monWed := Rule{Freq: FreqWeekly, ByDay: []time.Weekday{time.Wednesday, time.Monday}}
+	day31 := Rule{Freq: FreqMonthly, ByMonthDay: []int{31}}
+
+	tests := []struct {
+		name string
+		rule Rule
+		from time.Time
+		want string // in layout, empty if no occurrence is expected
+	}{
+		{"daily later today", daily.At(18, 0, ny), time.Date(2025, 9, 3, 14, 30, 0, 0, ny), "2025-09-03 18:00 EDT"},
+		{"daily tomorrow", daily.At(9, 0, ny), time.Date(2025, 9, 3, 14, 30, 0, 0, ny), "2025-09-04 09:00 EDT"},
+		{"strictly after", daily.At(9, 0, ny), time.Date(2025, 9, 3, 9, 0, 0, 0, ny), "2025-09-04 09:00 EDT"},
+		{"weekdays skip weekend", weekdays.At(9, 0, ny), time.Date(2025, 9, 5, 10, 0, 0, 0, ny), "2025-09-08 09:00 EDT"},
+		{"weekdays same day", weekdays.At(9, 0, ny), time.Date(2025, 9, 8, 8, 0, 0, 0, ny), "2025-09-08 09:00 EDT"},
+		{"mon and wed", monWed.At(9, 0, ny), time.Date(2025, 9, 8, 10, 0, 0, 0, ny), "2025-09-10 09:00 EDT"},
+		{"day 31 skips short month", day31.At(9, 0, ny), time.Date(2025, 9, 1, 0, 0, 0, 0, ny), "2025-10-31 09:00 EDT"},
+		{"day 31 skips february", day31.At(9, 0, ny), time.Date(2025, 1, 31, 10, 0, 0, 0, ny), "2025-03-31 09:00 EDT"},
+		{"dst forward gap", daily.At(2, 30, ny), time.Date(2025, 3, 8, 3, 0, 0, 0, ny), "2025-03-09 03:30 EDT"},
+		{"after dst forward gap", daily.At(2, 30, ny), time.Date(2025, 3, 9, 3, 30, 0, 0, ny), "2025-03-10 02:30 EDT"},
+		{"dst backward", daily.At(9, 0, ny), time.Date(2025, 11, 1, 10, 0, 0, 0, ny), "2025-11-02 09:00 EST"},
+		{"rule zone", daily.At(9, 0, msk), time.Date(2025, 9, 3, 5, 0, 0, 0, time.UTC), "2025-09-03 09:00 MSK"},
+		{"rule zone next day", daily.At(9, 0, msk), time.Date(2025, 9, 3, 6, 0, 0, 0, time.UTC), "2025-09-04 09:00 MSK"},
+		{"no zone uses from", daily.At(9, 0, nil), time.Date(2025, 9, 3, 10, 0, 0, 0, ny), "2025-09-04 09:00 EDT"},
+		{"beyond lookahead", Rule{Freq: FreqMonthly, ByMonthDay: []int{32}}.At(9, 0, ny), time.Date(2025, 9, 1, 0, 0, 0, 0, ny), ""},
+		{"empty weekly", Rule{Freq: FreqWeekly}.At(9, 0, ny), time.Date(2025, 9, 1, 0, 0, 0, 0, ny), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rule.Next(tt.from)
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want zero time", tt.from, got)
+				}
+				return
+			}
+
+			if got.IsZero() {
+				t.Fatalf("Next(%s) = zero time, want %s", tt.from, tt.want)
+			}
+			if s := got.Format(layout); s != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextKeepsTimeOfDay(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Occurrences across both DST changes of a year stay at 02:30 of wall clock time,
+	// except the one which does not exist on the day clocks go forward.
+	rule := Rule{Freq: FreqDaily}.At(2, 30, ny)
+	at := time.Date(2025, 1, 1, 2, 30, 0, 0, ny)
+	for i := 0; i < 365; i++ {
+		at = rule.Next(at)
+		h, m, _ := at.Clock()
+		if h == 3 && m == 30 && at.Month() == time.March && at.Day() == 9 {
+			continue
+		}
+		if h != 2 || m != 30 {
+			t.Fatalf("occurrence %d is at %s, want 02:30", i, at.Format(layout))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package reminder
+
+import "container/heap"
+
+// item is a reminder waiting in queue.
+type item struct {
+	event Event
+	index int
+}
+
+// queue is a min-heap of reminders ordered by DateTime.
+type queue []*item
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool { return q[i].event.DateTime.Before(q[j].event.DateTime) }
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(*q)
+	*q = append(*q, it)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*q = old[:n-1]
+	return it
+}
+
+// peek returns the earliest item or nil.
+func (q queue) peek() *item {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
+
+// remove deletes item from heap if it is still there.
+func (q *queue) remove(it *item) {
+	if it.index >= 0 && it.index < q.Len() && (*q)[it.index] == it {
+		heap.Remove(q, it.index)
+	}
+}
@@ -0,0 +1,226 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+)
+
+var benchSizes = []int{10_000, 100_000}
+
+var benchNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func benchEvent(i int) Event {
+	return Event{ID: i, OriginalID: i, ChatID: int64(i % 100), Text: "событие", DateTime: benchNow.Add(time.Duration(i) * time.Second)}
+}
+
+// newBenchManager returns ReminderManager with n queued events, lookahead covers all of them.
+func newBenchManager(n int) *ReminderManager {
+	rm := NewReminderManager(nil, storage.NewMemory(), clock.NewFake(benchNow), botManager.CatchUp{})
+	rm.lookahead = 365 * 24 * time.Hour
+
+	rm.mu.Lock()
+	for i := 1; i <= n; i++ {
+		rm.push(benchEvent(i))
+	}
+	rm.mu.Unlock()
+
+	return rm
+}
+
+func BenchmarkPush(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			rm := newBenchManager(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range b.N {
+				rm.mu.Lock()
+				rm.push(benchEvent(n + 1 + i%n))
+				rm.mu.Unlock()
+			}
+		})
+	}
+}
+
+// BenchmarkPopDue pops the earliest due event and pushes it back, so queue size stays n.
+func BenchmarkPopDue(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			rm := newBenchManager(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				due := rm.popDue(rm.queue.peek().event.DateTime)
+				if len(due) != 1 {
+					b.Fatalf("popped %d events, want 1", len(due))
+				}
+
+				e := due[0]
+				e.DateTime = e.DateTime.Add(time.Duration(n) * time.Second)
+				rm.mu.Lock()
+				rm.push(e)
+				rm.mu.Unlock()
+			}
+		})
+	}
+}
+
+// BenchmarkCancelReminder cancels a queued event and schedules it again, so queue size stays n.
+func BenchmarkCancelReminder(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			rm := newBenchManager(n)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range b.N {
+				e := benchEvent(1 + i%n)
+				rm.CancelReminder(e.OriginalID)
+				rm.ScheduleReminder(ctx, e)
+			}
+		})
+	}
+}
+
+// goroutineScheduler is the design replaced by ReminderManager queue: every scheduled reminder is a goroutine
+// waiting for its time, cancel funcs are kept in a map by event ID.
+type goroutineScheduler struct {
+	mu      sync.Mutex
+	cancels map[int]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (s *goroutineScheduler) schedule(ctx context.Context, e Event, now time.Time) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	if old, ok := s.cancels[e.OriginalID]; ok {
+		old()
+	}
+	s.cancels[e.OriginalID] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		t := time.NewTimer(e.DateTime.Sub(now))
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (s *goroutineScheduler) cancel(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+func (s *goroutineScheduler) stop() {
+	s.mu.Lock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// BenchmarkScheduleAll schedules n events with both designs and reports memory held per scheduled event.
+func BenchmarkScheduleAll(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("queue/%d", n), func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+
+			var held int64
+			for range b.N {
+				rm := NewReminderManager(nil, storage.NewMemory(), clock.NewFake(benchNow), botManager.CatchUp{})
+				rm.lookahead = 365 * 24 * time.Hour
+
+				before := memInUse()
+				for i := 1; i <= n; i++ {
+					rm.ScheduleReminder(ctx, benchEvent(i))
+				}
+				held += memInUse() - before
+				runtime.KeepAlive(rm)
+			}
+			b.ReportMetric(float64(held)/float64(b.N*n), "B/event")
+		})
+
+		b.Run(fmt.Sprintf("goroutines/%d", n), func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+
+			var held int64
+			for range b.N {
+				s := &goroutineScheduler{cancels: make(map[int]context.CancelFunc)}
+
+				before := memInUse()
+				for i := 1; i <= n; i++ {
+					s.schedule(ctx, benchEvent(i), benchNow)
+				}
+				held += memInUse() - before
+				s.stop()
+			}
+			b.ReportMetric(float64(held)/float64(b.N*n), "B/event")
+		})
+	}
+}
+
+// BenchmarkGoroutineCancel is BenchmarkCancelReminder of the goroutine-per-event design.
+func BenchmarkGoroutineCancel(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			ctx := context.Background()
+			s := &goroutineScheduler{cancels: make(map[int]context.CancelFunc)}
+			for i := 1; i <= n; i++ {
+				s.schedule(ctx, benchEvent(i), benchNow)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range b.N {
+				e := benchEvent(1 + i%n)
+				s.cancel(e.OriginalID)
+				s.schedule(ctx, e, benchNow)
+			}
+
+			b.StopTimer()
+			s.stop()
+		})
+	}
+}
+
+// memInUse returns bytes of heap and goroutine stacks in use after garbage collection.
+func memInUse() int64 {
+	runtime.GC()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapInuse + m.StackInuse)
+}
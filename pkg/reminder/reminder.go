@@ -1,99 +1,486 @@
 package reminder
 
 import (
+	"container/heap"
 	"context"
+	"errors"
 	"log"
 	"sync"
 	"time"
 
 	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
 	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/model"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// defaultPollInterval is how often the database is polled for upcoming events.
+	defaultPollInterval = time.Minute
+	// defaultLookahead is how far ahead events are loaded into the in-memory queue.
+	defaultLookahead = 5 * time.Minute
+	// lateAfter is how overdue reminder must be to be handled by catch-up policy,
+	// e.g. found by polling after instance which queued it had stopped.
+	lateAfter = time.Minute
+)
+
+var (
+	remindersScheduled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "bot_reminders_scheduled_total",
+		Help: "Number of reminders, notifications and delivery retries put to in-memory queue.",
+	})
+	remindersFired = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "bot_reminders_fired_total",
+		Help: "Number of queued reminders fired when due.",
+	})
+	remindersFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "bot_reminders_failed_total",
+		Help: "Number of reminders that were not delivered: dead-letter messages and processing errors.",
+	})
+	activeTimers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bot_reminders_active_timers",
+		Help: "Number of reminders waiting in in-memory queue.",
+	})
+	remindersOverdue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_reminders_overdue_total",
+		Help: "Number of overdue reminders fired after start by catch-up outcome: late, archived, dropped or skipped.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(remindersScheduled, remindersFired, remindersFailed, activeTimers, remindersOverdue)
+}
+
+// Event is a queued reminder: event itself, its advance notification if NotificationID is set
+// or next delivery attempt of outbox message if OutboxID is set.
 type Event struct {
-	ID         int
-	OriginalID int
-	ChatID     int64
-	Text       string
-	DateTime   time.Time
+	ID             int
+	OriginalID     int
+	NotificationID int
+	OutboxID       int
+	ChatID         int64
+	Text           string
+	DateTime       time.Time
+	Recurrence     string
+}
+
+// key identifies queued reminder.
+type key struct {
+	eventID, notificationID, outboxID int
+}
+
+func (e Event) key() key {
+	return key{eventID: e.OriginalID, notificationID: e.NotificationID, outboxID: e.OutboxID}
+}
+
+// NewEvent converts bot event to reminder event.
+func NewEvent(e model.Event) Event {
+	return Event{
+		ID:         e.ID,
+		OriginalID: e.OriginalID,
+		ChatID:     e.ChatID,
+		Text:       e.Text,
+		DateTime:   e.DateTime,
+		Recurrence: e.Recurrence,
+	}
+}
+
+// NewEvents converts bot event to reminder event followed by its pending advance notifications.
+func NewEvents(e model.Event) []Event {
+	events := []Event{NewEvent(e)}
+	for _, n := range e.Notifications {
+		if n.StatusID != db.EventStatusPending {
+			continue
+		}
+
+		ne := NewEvent(e)
+		ne.NotificationID = n.ID
+		ne.DateTime = n.DateTime
+		events = append(events, ne)
+	}
+
+	return events
+}
+
+func newDBEvent(e db.Event) Event {
+	event := Event{
+		ID:         e.UserEventID,
+		OriginalID: e.ID,
+		ChatID:     e.UserTgID,
+		Text:       e.Message,
+		DateTime:   e.SendAt,
+	}
+	if e.Recurrence != nil {
+		event.Recurrence = *e.Recurrence
+	}
+
+	return event
 }
 
+func newOutboxEvent(o db.Outbox) Event {
+	return Event{
+		OriginalID: o.EventID,
+		OutboxID:   o.ID,
+		ChatID:     o.UserTgID,
+		Text:       o.Message,
+		DateTime:   o.NextAttemptAt,
+	}
+}
+
+// ReminderManager keeps events due within lookahead window in a min-heap and fires them from a single loop.
+// Events are loaded from store by polling, each delivery claims its row in a store transaction,
+// so with PostgreSQL any number of bot instances can share one database and every reminder is delivered once.
+// Due reminder is put to outbox and sent from there, failed sends stay in outbox and are retried with backoff.
+// Reminders fired more than lateAfter after their time are handled by catch-up policy like at startup.
 type ReminderManager struct {
-	bm         *botManager.BotManager
-	eventsRepo db.EventsRepo
-	cancels    map[int]context.CancelFunc
-	mu         sync.RWMutex
+	bm           *botManager.BotManager
+	store        storage.EventStore
+	clock        clock.Clock
+	catchUp      botManager.CatchUp
+	pollInterval time.Duration
+	lookahead    time.Duration
+
+	queue queue
+	// queued items by event ID, so CancelReminder does not scan the whole queue
+	queued map[int]map[key]*item
+	wake   chan struct{}
+	mu     sync.Mutex
 }
 
-func NewReminderManager(bm *botManager.BotManager, eventsRepo db.EventsRepo) *ReminderManager {
+func NewReminderManager(bm *botManager.BotManager, store storage.EventStore, clk clock.Clock, catchUp botManager.CatchUp) *ReminderManager {
 	return &ReminderManager{
-		bm:         bm,
-		eventsRepo: eventsRepo,
-		cancels:    make(map[int]context.CancelFunc),
+		bm:           bm,
+		store:        store,
+		clock:        clk,
+		catchUp:      catchUp,
+		pollInterval: defaultPollInterval,
+		lookahead:    defaultLookahead,
+		queued:       make(map[int]map[key]*item),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Run polls store for upcoming events and delivers due reminders until ctx is done.
+func (rm *ReminderManager) Run(ctx context.Context) {
+	rm.poll(ctx)
+
+	poll := rm.clock.NewTicker(rm.pollInterval)
+	defer poll.Stop()
+
+	timer := rm.clock.NewTimer(rm.untilNext())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C():
+			rm.poll(ctx)
+		case <-rm.wake:
+		case <-timer.C():
+			for _, e := range rm.popDue(rm.clock.Now()) {
+				go rm.deliver(ctx, e)
+			}
+		}
+
+		timer.Reset(rm.untilNext())
 	}
 }
 
-func (rm *ReminderManager) ScheduleReminder(ctx context.Context, e Event) context.CancelFunc {
-	duration := time.Until(e.DateTime)
-	if duration <= 0 {
-		return nil
+// ScheduleReminder adds events to queue if they are due within lookahead window.
+// Later events are picked up by polling.
+func (rm *ReminderManager) ScheduleReminder(_ context.Context, events ...Event) {
+	to := rm.clock.Now().Add(rm.lookahead)
+
+	rm.mu.Lock()
+	for _, e := range events {
+		if !e.DateTime.After(to) {
+			rm.push(e)
+		}
 	}
+	rm.mu.Unlock()
 
-	ctx, cancel := context.WithCancel(ctx)
+	rm.notify()
+}
+
+// CancelReminder cancels reminder, advance notifications and queued delivery retries by internal event ID (Event.OriginalID).
+func (rm *ReminderManager) CancelReminder(eventID int) {
+	var canceled bool
 
 	rm.mu.Lock()
-	rm.cancels[e.ID] = cancel
+	for _, it := range rm.queued[eventID] {
+		rm.queue.remove(it)
+		canceled = true
+	}
+	delete(rm.queued, eventID)
+	activeTimers.Set(float64(rm.queue.Len()))
 	rm.mu.Unlock()
 
-	go func() {
-		defer func() {
-			rm.mu.Lock()
-			delete(rm.cancels, e.ID)
-			rm.mu.Unlock()
-			cancel()
-		}()
+	if canceled {
+		rm.notify()
+		log.Printf("Напоминание ID=%d отменено", eventID)
+	}
+}
 
-		select {
-		case <-time.After(duration):
-			event, err := rm.bm.GetEventByID(ctx, e.ID)
-			if err != nil {
-				log.Printf("Ошибка проверки события: %v", err)
-				return
-			}
+// RescheduleReminder cancels current reminders of event and schedules them with new time and text.
+// All events must belong to one event, e.g. result of NewEvents.
+func (rm *ReminderManager) RescheduleReminder(ctx context.Context, events ...Event) {
+	if len(events) == 0 {
+		return
+	}
 
-			if event != nil {
-				rm.bm.SendReminder(ctx, e.ChatID, e.Text)
-				log.Printf("Отправлено напоминание: ID=%d", e.ID)
+	rm.CancelReminder(events[0].OriginalID)
+	rm.ScheduleReminder(ctx, events...)
+}
 
-				err := rm.bm.DeleteEventByID(ctx, e.ID)
-				if err != nil {
-					log.Printf("Ошибка удаления события после напоминания: %v", err)
-				}
-			} else {
-				log.Printf("Событие ID=%d было удалено", e.ID)
-			}
+// poll loads pending events and outbox messages due within lookahead window into queue.
+func (rm *ReminderManager) poll(ctx context.Context) {
+	status, to := db.EventStatusPending, rm.clock.Now().Add(rm.lookahead)
 
-		case <-ctx.Done():
-			log.Printf("Напоминание ID=%d отменено", e.ID)
+	events, err := rm.store.EventsByFilters(ctx, &db.EventSearch{StatusID: &status, SendAtTo: &to}, db.PagerNoLimit)
+	if err != nil {
+		log.Printf("Ошибка загрузки напоминаний: %v", err)
+		return
+	}
+
+	notifications, err := rm.store.NotificationsByFilters(ctx, &db.NotificationSearch{StatusID: &status, SendAtTo: &to})
+	if err != nil {
+		log.Printf("Ошибка загрузки уведомлений: %v", err)
+	}
+
+	// message stays in sending status after its sender crashed, it is retried when lease expires
+	outbox, err := rm.store.OutboxesByFilters(ctx, &db.OutboxSearch{
+		StatusIDs:       []int{db.EventStatusPending, db.EventStatusSending},
+		NextAttemptAtTo: &to,
+	})
+	if err != nil {
+		log.Printf("Ошибка загрузки исходящих сообщений: %v", err)
+	}
+
+	rm.mu.Lock()
+	for _, e := range events {
+		rm.push(newDBEvent(e))
+	}
+	for _, n := range notifications {
+		if n.Event == nil || n.Event.StatusID != db.EventStatusPending {
+			continue
+		}
+
+		e := newDBEvent(*n.Event)
+		e.NotificationID = n.ID
+		e.DateTime = n.SendAt
+		rm.push(e)
+	}
+	for _, o := range outbox {
+		rm.push(newOutboxEvent(o))
+	}
+	rm.mu.Unlock()
+}
+
+// push adds or replaces event in queue. rm.mu must be held.
+func (rm *ReminderManager) push(e Event) {
+	k := e.key()
+	if it, ok := rm.queued[k.eventID][k]; ok {
+		if it.event.DateTime.Equal(e.DateTime) && it.event.Text == e.Text {
 			return
 		}
-	}()
+		rm.queue.remove(it)
+	}
 
-	return cancel
+	it := &item{event: e}
+	heap.Push(&rm.queue, it)
+	if rm.queued[k.eventID] == nil {
+		rm.queued[k.eventID] = make(map[key]*item)
+	}
+	rm.queued[k.eventID][k] = it
+	remindersScheduled.Inc()
+	activeTimers.Set(float64(rm.queue.Len()))
 }
 
-func (rm *ReminderManager) CancelReminder(eventID int) {
-	rm.mu.RLock()
-	cancel, exists := rm.cancels[eventID]
-	rm.mu.RUnlock()
+// popDue removes and returns all events due at now.
+func (rm *ReminderManager) popDue(now time.Time) []Event {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-	if exists {
-		cancel()
+	var due []Event
+	for it := rm.queue.peek(); it != nil && !it.event.DateTime.After(now); it = rm.queue.peek() {
+		heap.Pop(&rm.queue)
+		rm.forget(it.event.key())
+		due = append(due, it.event)
+	}
+	remindersFired.Add(float64(len(due)))
+	activeTimers.Set(float64(rm.queue.Len()))
 
-		rm.mu.Lock()
-		delete(rm.cancels, eventID)
-		rm.mu.Unlock()
+	return due
+}
 
-		log.Printf("Напоминание ID=%d отменено", eventID)
+// forget removes key from queued items. rm.mu must be held.
+func (rm *ReminderManager) forget(k key) {
+	delete(rm.queued[k.eventID], k)
+	if len(rm.queued[k.eventID]) == 0 {
+		delete(rm.queued, k.eventID)
+	}
+}
+
+// untilNext returns duration until the earliest queued event or poll interval if queue is empty.
+func (rm *ReminderManager) untilNext() time.Duration {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	it := rm.queue.peek()
+	if it == nil {
+		return rm.pollInterval
+	}
+
+	if d := it.event.DateTime.Sub(rm.clock.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// notify wakes up Run loop to recalculate timer.
+func (rm *ReminderManager) notify() {
+	select {
+	case rm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver claims event row and puts reminder to outbox in one transaction, then sends it.
+// Overdue reminder is marked late or archived, dropped or skipped by catch-up policy.
+// Recurring events are moved to the next occurrence and put back to queue.
+func (rm *ReminderManager) deliver(ctx context.Context, e Event) {
+	switch {
+	case e.OutboxID != 0:
+		rm.dispatch(ctx, e.OutboxID)
+		return
+	case e.NotificationID != 0:
+		rm.deliverNotification(ctx, e)
+		return
+	}
+
+	var (
+		outboxID int
+		next     *model.Event
+		// outcome of catch-up policy if reminder is overdue
+		outcome string
+		overdue time.Duration
+	)
+
+	err := rm.store.RunInTransaction(ctx, func(s storage.EventStore) error {
+		bm := rm.bm.WithStore(s)
+		// transaction may be run again, so results of previous run are reset
+		outboxID, next, outcome = 0, nil, ""
+
+		event, err := bm.ClaimEvent(ctx, e.ChatID, e.ID)
+		if errors.Is(err, botManager.ErrEventNotFound) {
+			log.Printf("Событие ID=%d удалено или обрабатывается другим экземпляром", e.OriginalID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !event.DateTime.Equal(e.DateTime) {
+			log.Printf("Событие ID=%d было перенесено", e.OriginalID)
+			return nil
+		}
+
+		if overdue = rm.clock.Now().Sub(event.DateTime); overdue > lateAfter {
+			if outcome, outboxID, err = bm.CatchUpEvent(ctx, *event, overdue, rm.catchUp); err != nil {
+				return err
+			}
+		} else if outboxID, err = bm.EnqueueReminder(ctx, *event); err != nil {
+			return err
+		}
+
+		if event.Recurrence == "" {
+			return nil
+		}
+
+		next, err = bm.NextOccurrence(ctx, e.ChatID, e.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Ошибка обработки напоминания ID=%d: %v", e.OriginalID, err)
+		remindersFailed.Inc()
+		return
+	}
+
+	if outcome != "" {
+		remindersOverdue.WithLabelValues(outcome).Inc()
+		log.Printf("Напоминание ID=%d опоздало на %s, обработано как %s", e.OriginalID, overdue.Round(time.Second), outcome)
+	}
+
+	if outboxID != 0 {
+		rm.dispatch(ctx, outboxID)
+	}
+
+	if next != nil {
+		log.Printf("Следующий повтор ID=%d: %s", next.OriginalID, next.DateTime)
+		rm.ScheduleReminder(ctx, NewEvents(*next)...)
+	}
+}
+
+// deliverNotification claims notification row and puts advance notification to outbox in one transaction, then sends it.
+func (rm *ReminderManager) deliverNotification(ctx context.Context, e Event) {
+	var outboxID int
+
+	err := rm.store.RunInTransaction(ctx, func(s storage.EventStore) error {
+		bm := rm.bm.WithStore(s)
+		outboxID = 0
+
+		n, event, err := bm.ClaimNotification(ctx, e.NotificationID)
+		if errors.Is(err, botManager.ErrEventNotFound) {
+			log.Printf("Уведомление ID=%d отменено или обрабатывается другим экземпляром", e.NotificationID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !n.DateTime.Equal(e.DateTime) {
+			log.Printf("Уведомление ID=%d было перенесено", e.NotificationID)
+			return nil
+		}
+
+		outboxID, err = bm.EnqueueNotification(ctx, *n, *event)
+		return err
+	})
+	if err != nil {
+		log.Printf("Ошибка обработки уведомления ID=%d: %v", e.NotificationID, err)
+		remindersFailed.Inc()
+		return
+	}
+
+	if outboxID != 0 {
+		rm.dispatch(ctx, outboxID)
+	}
+}
+
+// dispatch sends outbox message and queues its next attempt if sending failed.
+func (rm *ReminderManager) dispatch(ctx context.Context, id int) {
+	outbox, err := rm.bm.SendOutbox(ctx, id)
+	if errors.Is(err, botManager.ErrEventNotFound) {
+		log.Printf("Сообщение ID=%d уже обработано или обрабатывается другим экземпляром", id)
+		return
+	} else if err != nil {
+		log.Printf("Ошибка отправки сообщения ID=%d: %v", id, err)
+		remindersFailed.Inc()
+		return
+	}
+
+	switch outbox.StatusID {
+	case db.EventStatusSent:
+		log.Printf("Отправлено напоминание: ID=%d, сообщение ID=%d", outbox.EventID, outbox.ID)
+	case db.EventStatusCancelled:
+		log.Printf("Сообщение ID=%d отменено: событие ID=%d отменено или перенесено", outbox.ID, outbox.EventID)
+	case db.EventStatusFailed:
+		log.Printf("Сообщение ID=%d не доставлено, попыток %d: %s", outbox.ID, outbox.Attempts, *outbox.LastError)
+		remindersFailed.Inc()
+	case db.EventStatusPending:
+		log.Printf("Ошибка отправки сообщения ID=%d, попытка %d: %s, повтор в %s",
+			outbox.ID, outbox.Attempts, *outbox.LastError, outbox.NextAttemptAt)
+		rm.ScheduleReminder(ctx, newOutboxEvent(*outbox))
 	}
 }
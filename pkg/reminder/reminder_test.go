@@ -0,0 +1,411 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	botManager "github.com/kanef1/event-reminder-bot/pkg/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/sender"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/storage/storagetest"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+var testNow = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// waitTimeout is how long tests wait for reminders sent by Run.
+const waitTimeout = 5 * time.Second
+
+var testCatchUp = botManager.CatchUp{Window: time.Hour}
+
+// instance is a bot instance with ReminderManager sending reminders to fake API.
+type instance struct {
+	bm  *botManager.BotManager
+	rm  *ReminderManager
+	clk *clock.Fake
+}
+
+func newInstance(t *testing.T, api *telegramtest.Server, store storage.EventStore, clk *clock.Fake) instance {
+	return newPolicyInstance(t, api, store, clk, testCatchUp)
+}
+
+func newPolicyInstance(t *testing.T, api *telegramtest.Server, store storage.EventStore, clk *clock.Fake, catchUp botManager.CatchUp) instance {
+	t.Helper()
+
+	b, err := bot.New(telegramtest.Token, bot.WithServerURL(api.URL()), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bm := botManager.NewBotManager(b, sender.New(b, clock.Real, sender.Limits{}), store, clk, time.UTC)
+	return instance{bm: bm, rm: NewReminderManager(bm, store, clk, catchUp), clk: clk}
+}
+
+// newTestInstance returns instance on empty memory store and fake API.
+func newTestInstance(t *testing.T) (instance, *telegramtest.Server) {
+	t.Helper()
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+
+	return newInstance(t, api, storage.NewMemory(), clock.NewFake(testNow)), api
+}
+
+// step does what Run does when its timer fires, but returns only after due reminders are delivered,
+// so tests need not wait to make sure that nothing is sent.
+func (i instance) step(ctx context.Context) {
+	for _, e := range i.rm.popDue(i.clk.Now()) {
+		i.rm.deliver(ctx, e)
+	}
+}
+
+// addEvent adds event at testNow+after which is not scheduled yet.
+func (i instance) addEvent(t *testing.T, after time.Duration, text string) Event {
+	t.Helper()
+
+	e, err := i.bm.AddEventAt(context.Background(), 1, 1, testNow.Add(after), text)
+	if err != nil {
+		t.Fatalf("AddEventAt: %v", err)
+	}
+
+	return NewEvent(*e)
+}
+
+// queued returns number of queued reminders.
+func (i instance) queued() int {
+	i.rm.mu.Lock()
+	defer i.rm.mu.Unlock()
+
+	return i.rm.queue.Len()
+}
+
+// wantReminder checks that reminder is the next message sent so far.
+func wantReminder(t *testing.T, api *telegramtest.Server, text string) {
+	t.Helper()
+
+	call, err := api.Wait("sendMessage", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "🔔 Напоминание: " + text; call.Text() != want {
+		t.Fatalf("sent %q, want %q", call.Text(), want)
+	}
+}
+
+// wantNothing checks that no message is sent since the previous check.
+func wantNothing(t *testing.T, api *telegramtest.Server) {
+	t.Helper()
+
+	if call, err := api.Wait("sendMessage", 0); err == nil {
+		t.Fatalf("unexpected message %q", call.Text())
+	}
+}
+
+func TestTwoInstancesDeliverOnce(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testDeliverOnce(t, storage.NewMemory())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		testDeliverOnce(t, storage.NewPostgres(storagetest.Postgres(t)))
+	})
+}
+
+// testDeliverOnce fires the same due events in two instances sharing store at once and checks that every event is sent once.
+func testDeliverOnce(t *testing.T, store storage.EventStore) {
+	const events = 20
+	ctx := context.Background()
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+	clk := clock.NewFake(testNow)
+
+	first, second := newInstance(t, api, store, clk), newInstance(t, api, store, clk)
+	for chatID := int64(1); chatID <= events; chatID++ {
+		if _, err := first.bm.AddEvent(ctx, chatID, chatID, "2030-01-01 10:01 событие"); err != nil {
+			t.Fatalf("AddEvent: %v", err)
+		}
+	}
+
+	// both instances load all events by polling
+	first.rm.poll(ctx)
+	second.rm.poll(ctx)
+	clk.Advance(2 * time.Minute)
+
+	var wg sync.WaitGroup
+	for _, i := range []instance{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.step(ctx)
+		}()
+	}
+	wg.Wait()
+
+	sent := make(map[int64]int)
+	for _, call := range api.Calls("sendMessage") {
+		sent[call.ChatID()]++
+	}
+	for chatID := int64(1); chatID <= events; chatID++ {
+		if sent[chatID] != 1 {
+			t.Errorf("chat %d got %d reminders, want 1", chatID, sent[chatID])
+		}
+	}
+	if len(sent) != events {
+		t.Errorf("reminders sent to %d chats, want %d: %v", len(sent), events, sent)
+	}
+}
+
+// Scheduled event is due before the next poll, so only the queue can fire it.
+func TestScheduleReminder(t *testing.T) {
+	ctx := context.Background()
+	i, api := newTestInstance(t)
+	i.rm.ScheduleReminder(ctx, i.addEvent(t, 30*time.Second, "встреча"))
+
+	i.clk.Advance(29 * time.Second)
+	i.step(ctx)
+	wantNothing(t, api)
+
+	i.clk.Advance(time.Second)
+	i.step(ctx)
+	wantReminder(t, api, "встреча")
+}
+
+// Events beyond lookahead window are left for polling.
+func TestScheduleReminderBeyondLookahead(t *testing.T) {
+	i, _ := newTestInstance(t)
+	i.rm.ScheduleReminder(context.Background(), i.addEvent(t, defaultLookahead+time.Second, "встреча"))
+
+	if n := i.queued(); n != 0 {
+		t.Errorf("%d reminders queued, want 0", n)
+	}
+}
+
+func TestCancelReminder(t *testing.T) {
+	ctx := context.Background()
+	i, api := newTestInstance(t)
+	e := i.addEvent(t, 30*time.Second, "встреча")
+	i.rm.ScheduleReminder(ctx, e)
+	i.rm.CancelReminder(e.OriginalID)
+
+	if n := i.queued(); n != 0 {
+		t.Errorf("%d reminders queued after cancel, want 0", n)
+	}
+
+	i.clk.Advance(30 * time.Second)
+	i.step(ctx)
+	wantNothing(t, api)
+}
+
+// Deleted event leaves the queue like in handlers of /delete and of the list button, and is never sent.
+func TestDeletedEventNeverSent(t *testing.T) {
+	ctx := context.Background()
+	i, api := newTestInstance(t)
+	e := i.addEvent(t, 30*time.Second, "встреча")
+	i.rm.ScheduleReminder(ctx, e)
+
+	deleted, err := i.bm.DeleteEventByID(ctx, e.ChatID, e.ChatID, e.ID)
+	if err != nil {
+		t.Fatalf("DeleteEventByID: %v", err)
+	}
+	i.rm.CancelReminder(deleted.OriginalID)
+
+	if n := i.queued(); n != 0 {
+		t.Errorf("%d reminders queued after delete, want 0", n)
+	}
+
+	i.clk.Advance(time.Hour)
+	i.rm.poll(ctx)
+	i.step(ctx)
+	wantNothing(t, api)
+}
+
+func TestRescheduleReminder(t *testing.T) {
+	ctx := context.Background()
+	i, api := newTestInstance(t)
+	e := i.addEvent(t, 30*time.Second, "встреча")
+	i.rm.ScheduleReminder(ctx, e)
+
+	updated, err := i.bm.UpdateEventTime(ctx, e.ChatID, e.ChatID, e.ID, "2030-01-01", "10:02")
+	if err != nil {
+		t.Fatalf("UpdateEventTime: %v", err)
+	}
+	i.rm.RescheduleReminder(ctx, NewEvents(*updated)...)
+
+	// old time passes, the reminder fires at its new time once
+	i.clk.Advance(30 * time.Second)
+	i.step(ctx)
+	wantNothing(t, api)
+
+	i.clk.Advance(time.Minute)
+	i.step(ctx)
+	wantNothing(t, api)
+
+	i.clk.Advance(30 * time.Second)
+	i.step(ctx)
+	wantReminder(t, api, "встреча")
+
+	i.clk.Advance(time.Hour)
+	i.rm.poll(ctx)
+	i.step(ctx)
+	wantNothing(t, api)
+}
+
+// Events stored before start or by another instance are fired by polling, later ones are not fired early.
+func TestPollStoredEvents(t *testing.T) {
+	ctx := context.Background()
+	i, api := newTestInstance(t)
+
+	due := map[string]time.Time{"до запуска": testNow.Add(time.Minute)}
+	i.addEvent(t, time.Minute, "до запуска")
+	i.rm.poll(ctx)
+
+	// stored after the first poll and not scheduled, e.g. by another instance
+	for text, after := range map[string]time.Duration{"после запуска": 3 * time.Minute, "за окном": 20 * time.Minute} {
+		i.addEvent(t, after, text)
+		due[text] = testNow.Add(after)
+	}
+
+	// every minute Run polls store and fires due reminders
+	sent := make(map[string]time.Time)
+	for i.clk.Now().Before(testNow.Add(time.Hour)) {
+		i.clk.Advance(defaultPollInterval)
+		i.rm.poll(ctx)
+		i.step(ctx)
+
+		for {
+			call, err := api.Wait("sendMessage", 0)
+			if err != nil {
+				break
+			}
+			sent[call.Text()] = i.clk.Now()
+		}
+	}
+
+	for text, at := range due {
+		sentAt, ok := sent["🔔 Напоминание: "+text]
+		if !ok {
+			t.Errorf("%q is not sent", text)
+		} else if !sentAt.Equal(at) {
+			t.Errorf("%q is sent at %s, want %s", text, sentAt, at)
+		}
+	}
+	if len(sent) != len(due) {
+		t.Errorf("sent %v, want %d reminders", sent, len(due))
+	}
+}
+
+// Reminders found by polling long after their time, e.g. queued by a stopped instance, follow catch-up policy.
+func TestPollOverdueEvents(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		t.Run(fmt.Sprint("drop=", drop), func(t *testing.T) {
+			ctx := context.Background()
+			api := telegramtest.NewServer()
+			t.Cleanup(api.Close)
+			store := storage.NewMemory()
+			i := newPolicyInstance(t, api, store, clock.NewFake(testNow), botManager.CatchUp{Window: time.Hour, Drop: drop})
+
+			old := i.addEvent(t, time.Minute, "старое")
+			recent := i.addEvent(t, 90*time.Minute, "недавнее")
+			recurring, err := i.bm.AddRecurringEvent(ctx, 1, 1, []string{"day", "10:05", "повтор"})
+			if err != nil {
+				t.Fatalf("AddRecurringEvent: %v", err)
+			}
+
+			i.clk.Advance(2 * time.Hour)
+			i.rm.poll(ctx)
+			i.step(ctx)
+
+			call, err := api.Wait("sendMessage", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "🔔 Напоминание (с опозданием, время события 2030-01-01 11:30): недавнее"; call.Text() != want {
+				t.Errorf("sent %q, want %q", call.Text(), want)
+			}
+			wantNothing(t, api)
+
+			event := func(id int) *db.Event {
+				t.Helper()
+				e, err := store.OneEvent(ctx, &db.EventSearch{ID: &id})
+				if err != nil {
+					t.Fatalf("OneEvent: %v", err)
+				}
+				return e
+			}
+
+			switch e := event(old.OriginalID); {
+			case drop && e != nil:
+				t.Errorf("event older than window is not dropped: %+v", e)
+			case !drop && (e == nil || e.StatusID != db.EventStatusFailed):
+				t.Errorf("event older than window is not archived: %+v", e)
+			}
+			if e := event(recent.OriginalID); e == nil || e.StatusID != db.EventStatusSent {
+				t.Errorf("late event is not sent: %+v", e)
+			}
+			if e := event(recurring.OriginalID); e == nil || e.StatusID != db.EventStatusPending ||
+				!e.SendAt.Equal(time.Date(2030, 1, 2, 10, 5, 0, 0, time.UTC)) {
+				t.Errorf("skipped occurrence is not moved to the next day: %+v", e)
+			}
+		})
+	}
+}
+
+// polledStore signals the first poll of ReminderManager.
+type polledStore struct {
+	storage.EventStore
+	polled chan struct{}
+}
+
+func (s polledStore) EventsByFilters(ctx context.Context, search *db.EventSearch, pager db.Pager) ([]db.Event, error) {
+	events, err := s.EventStore.EventsByFilters(ctx, search, pager)
+	select {
+	case s.polled <- struct{}{}:
+	default:
+	}
+
+	return events, err
+}
+
+// Run fires reminders by its timer when clock passes their time.
+func TestRun(t *testing.T) {
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+	store := polledStore{EventStore: storage.NewMemory(), polled: make(chan struct{}, 1)}
+	i := newInstance(t, api, store, clock.NewFake(testNow))
+	i.addEvent(t, time.Minute, "по опросу")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.rm.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	select {
+	case <-store.polled:
+	case <-time.After(waitTimeout):
+		t.Fatal("ReminderManager did not poll store")
+	}
+	i.rm.ScheduleReminder(ctx, i.addEvent(t, 30*time.Second, "по расписанию"))
+
+	for _, text := range []string{"по расписанию", "по опросу"} {
+		i.clk.Advance(30 * time.Second)
+		call, err := api.Wait("sendMessage", waitTimeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "🔔 Напоминание: " + text; call.Text() != want {
+			t.Fatalf("sent %q, want %q", call.Text(), want)
+		}
+	}
+}
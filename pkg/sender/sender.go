@@ -0,0 +1,299 @@
+// Package sender is an outbound pipeline of Telegram messages that keeps bot within API rate limits.
+//
+// Every message or edit of message waits in a queue for a token of the global bucket and a token of its chat bucket,
+// answers to callback queries wait for a token of the global bucket only.
+// Queue is ordered by priority, so reminders are sent before command replies, and by arrival within priority.
+package sender
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority of message, lower value is sent first.
+type Priority int
+
+const (
+	PriorityReminder Priority = iota
+	PriorityReply
+
+	priorities = 2
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityReminder:
+		return "reminder"
+	case PriorityReply:
+		return "reply"
+	}
+
+	return fmt.Sprintf("priority_%d", int(p))
+}
+
+// Limits are rates in messages per second and bursts of token buckets. Zero Limits disable rate limiting.
+type Limits struct {
+	GlobalRate  float64
+	GlobalBurst int
+	ChatRate    float64
+	ChatBurst   int
+}
+
+// idleInterval is how long Run sleeps when queue is empty.
+const idleInterval = time.Minute
+
+var (
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bot_send_queue_depth",
+		Help: "Number of outgoing messages waiting for rate limiter.",
+	}, []string{"priority"})
+	waitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_send_wait_seconds",
+		Help:    "Time outgoing message waited for rate limiter.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"priority"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth, waitSeconds)
+}
+
+// request is a message waiting in queue, ready is closed when it may be sent.
+type request struct {
+	chat     string
+	priority Priority
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+// Sender sends messages of bot through rate limiter. Queue is served by Run, which must be running.
+type Sender struct {
+	b      *bot.Bot
+	clock  clock.Clock
+	limits Limits
+
+	mu     sync.Mutex
+	queue  [priorities][]*request
+	global bucket
+	chats  map[string]*bucket
+	wake   chan struct{}
+}
+
+func New(b *bot.Bot, clk clock.Clock, limits Limits) *Sender {
+	return &Sender{
+		b:      b,
+		clock:  clk,
+		limits: limits,
+		global: bucket{tokens: float64(limits.GlobalBurst), updated: clk.Now()},
+		chats:  make(map[string]*bucket),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SendMessage waits for its turn and sends message. It returns ctx error if ctx is done while message is queued.
+func (s *Sender) SendMessage(ctx context.Context, priority Priority, params *bot.SendMessageParams) (*models.Message, error) {
+	if err := s.wait(ctx, priority, fmt.Sprint(params.ChatID)); err != nil {
+		return nil, err
+	}
+
+	return s.b.SendMessage(ctx, params)
+}
+
+// EditMessageText waits for its turn and edits message text, edits count against limits of chat like new messages.
+func (s *Sender) EditMessageText(ctx context.Context, priority Priority, params *bot.EditMessageTextParams) (*models.Message, error) {
+	if err := s.wait(ctx, priority, fmt.Sprint(params.ChatID)); err != nil {
+		return nil, err
+	}
+
+	return s.b.EditMessageText(ctx, params)
+}
+
+// EditMessageReplyMarkup waits for its turn and edits message keyboard.
+func (s *Sender) EditMessageReplyMarkup(ctx context.Context, priority Priority, params *bot.EditMessageReplyMarkupParams) (*models.Message, error) {
+	if err := s.wait(ctx, priority, fmt.Sprint(params.ChatID)); err != nil {
+		return nil, err
+	}
+
+	return s.b.EditMessageReplyMarkup(ctx, params)
+}
+
+// AnswerCallbackQuery waits for its turn and answers callback query. Answer is not a message of chat,
+// so it takes a token of the global bucket only.
+func (s *Sender) AnswerCallbackQuery(ctx context.Context, priority Priority, params *bot.AnswerCallbackQueryParams) (bool, error) {
+	if err := s.wait(ctx, priority, ""); err != nil {
+		return false, err
+	}
+
+	return s.b.AnswerCallbackQuery(ctx, params)
+}
+
+// wait queues request of chat and waits until it may be sent, empty chat is limited by the global bucket only.
+func (s *Sender) wait(ctx context.Context, priority Priority, chat string) error {
+	if s.limits == (Limits{}) {
+		return nil
+	}
+
+	if priority < 0 || priority >= priorities {
+		priority = PriorityReply
+	}
+
+	r := &request{chat: chat, priority: priority, queuedAt: s.clock.Now(), ready: make(chan struct{})}
+
+	s.mu.Lock()
+	s.queue[priority] = append(s.queue[priority], r)
+	queueDepth.WithLabelValues(priority.String()).Inc()
+	s.mu.Unlock()
+	s.notify()
+
+	select {
+	case <-r.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remove(r) {
+		queueDepth.WithLabelValues(priority.String()).Dec()
+	}
+	return ctx.Err()
+}
+
+// Run serves queue until ctx is done.
+func (s *Sender) Run(ctx context.Context) {
+	timer := s.clock.NewTimer(idleInterval)
+	defer timer.Stop()
+
+	for {
+		// clock may move while queue is served, then timer fires earlier and release checks queue again
+		start := s.clock.Now()
+		next := s.release()
+		timer.Reset(next - s.clock.Now().Sub(start))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C():
+		}
+	}
+}
+
+// release lets queued messages go while there are tokens and returns duration until the next token is needed.
+func (s *Sender) release() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.global.refill(now, s.limits.GlobalRate, s.limits.GlobalBurst)
+
+	next, queued := idleInterval, false
+	for p := range s.queue {
+		for i := 0; i < len(s.queue[p]); {
+			queued = true
+			if s.global.tokens < 1 {
+				return s.global.until(s.limits.GlobalRate)
+			}
+
+			r := s.queue[p][i]
+			var chat *bucket
+			if r.chat != "" {
+				chat = s.chat(r.chat, now)
+			}
+			if chat != nil && chat.tokens < 1 {
+				next = min(next, chat.until(s.limits.ChatRate))
+				i++
+				continue
+			}
+
+			s.global.tokens--
+			if chat != nil {
+				chat.tokens--
+			}
+			s.queue[p] = append(s.queue[p][:i], s.queue[p][i+1:]...)
+			queueDepth.WithLabelValues(r.priority.String()).Dec()
+			waitSeconds.WithLabelValues(r.priority.String()).Observe(now.Sub(r.queuedAt).Seconds())
+			close(r.ready)
+		}
+	}
+
+	if !queued {
+		s.prune(now)
+	}
+
+	return next
+}
+
+// chat returns refilled bucket of chat. s.mu must be held.
+func (s *Sender) chat(id string, now time.Time) *bucket {
+	b, ok := s.chats[id]
+	if !ok {
+		b = &bucket{tokens: float64(s.limits.ChatBurst), updated: now}
+		s.chats[id] = b
+	}
+
+	b.refill(now, s.limits.ChatRate, s.limits.ChatBurst)
+	return b
+}
+
+// prune forgets full chat buckets, they are recreated full. s.mu must be held.
+func (s *Sender) prune(now time.Time) {
+	for id, b := range s.chats {
+		if b.refill(now, s.limits.ChatRate, s.limits.ChatBurst); b.tokens >= float64(s.limits.ChatBurst) {
+			delete(s.chats, id)
+		}
+	}
+}
+
+// remove removes request from queue and reports whether it was queued. s.mu must be held.
+func (s *Sender) remove(r *request) bool {
+	q := s.queue[r.priority]
+	for i := range q {
+		if q[i] == r {
+			s.queue[r.priority] = append(q[:i], q[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// notify wakes up Run loop to serve new request.
+func (s *Sender) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*rate, float64(burst))
+	}
+	b.updated = now
+}
+
+// until returns duration until bucket has a whole token.
+func (b *bucket) until(rate float64) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	} else if rate <= 0 {
+		return idleInterval
+	}
+
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
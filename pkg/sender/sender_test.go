@@ -0,0 +1,251 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/kanef1/event-reminder-bot/pkg/clock"
+	"github.com/kanef1/event-reminder-bot/pkg/telegramtest"
+)
+
+// timeout is how long test waits for released messages to reach fake API.
+const timeout = 5 * time.Second
+
+// instance is Sender whose queue is served by test: release is called explicitly instead of Run,
+// so test decides when tokens are refilled and which messages must still be queued.
+type instance struct {
+	*Sender
+	api *telegramtest.Server
+	clk *clock.Fake
+}
+
+func newInstance(t *testing.T, limits Limits) instance {
+	t.Helper()
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+
+	b, err := bot.New(telegramtest.Token, bot.WithServerURL(api.URL()), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := clock.NewFake(time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC))
+	return instance{Sender: New(b, clk, limits), api: api, clk: clk}
+}
+
+// send queues message and returns channel with result of SendMessage. It returns when message is queued.
+func (s instance) send(ctx context.Context, priority Priority, chatID int64, text string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.SendMessage(ctx, priority, &bot.SendMessageParams{ChatID: chatID, Text: text})
+		done <- err
+	}()
+
+	// wait notifies Run after request is queued
+	<-s.wake
+	return done
+}
+
+// queued returns number of messages waiting for tokens.
+func (s instance) queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, q := range s.queue {
+		n += len(q)
+	}
+
+	return n
+}
+
+// wantSent checks that messages with texts are sent, in any order as they are released together,
+// and that queued messages are left and no other message is sent.
+func (s instance) wantSent(t *testing.T, queued int, texts ...string) {
+	t.Helper()
+
+	var sent []string
+	for range texts {
+		call, err := s.api.Wait("sendMessage", timeout)
+		if err != nil {
+			t.Fatalf("sent %q, want %q: %v", sent, texts, err)
+		}
+		sent = append(sent, call.Text())
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(sent)), slices.Sorted(slices.Values(texts))) {
+		t.Fatalf("sent %q, want %q", sent, texts)
+	}
+
+	if n := s.queued(); n != queued {
+		t.Fatalf("%d messages queued, want %d", n, queued)
+	}
+	if call, err := s.api.Wait("sendMessage", 0); err == nil {
+		t.Fatalf("unexpected message %q", call.Text())
+	}
+}
+
+func TestBurstExhaustion(t *testing.T) {
+	ctx := context.Background()
+	s := newInstance(t, Limits{GlobalRate: 1, GlobalBurst: 3, ChatRate: 100, ChatBurst: 100})
+
+	for i, text := range []string{"1", "2", "3", "4", "5"} {
+		s.send(ctx, PriorityReply, int64(i+1), text)
+	}
+
+	if next := s.release(); next != time.Second {
+		t.Errorf("release after burst = %s, want 1s until the next token", next)
+	}
+	s.wantSent(t, 2, "1", "2", "3")
+
+	// tokens are not refilled without time passing
+	s.release()
+	s.wantSent(t, 2)
+
+	s.clk.Advance(time.Second)
+	s.release()
+	s.wantSent(t, 1, "4")
+
+	s.clk.Advance(time.Second)
+	s.release()
+	s.wantSent(t, 0, "5")
+}
+
+func TestPerChatWait(t *testing.T) {
+	ctx := context.Background()
+	s := newInstance(t, Limits{GlobalRate: 100, GlobalBurst: 100, ChatRate: 1, ChatBurst: 1})
+
+	s.send(ctx, PriorityReply, 1, "первое в чат 1")
+	s.send(ctx, PriorityReply, 1, "второе в чат 1")
+	s.send(ctx, PriorityReply, 2, "первое в чат 2")
+
+	// busy chat does not hold messages of other chats
+	if next := s.release(); next != time.Second {
+		t.Errorf("release = %s, want 1s until token of chat", next)
+	}
+	s.wantSent(t, 1, "первое в чат 1", "первое в чат 2")
+
+	s.clk.Advance(time.Second / 2)
+	s.release()
+	s.wantSent(t, 1)
+
+	s.clk.Advance(time.Second / 2)
+	s.release()
+	s.wantSent(t, 0, "второе в чат 1")
+}
+
+func TestReminderBeforeReply(t *testing.T) {
+	ctx := context.Background()
+	s := newInstance(t, Limits{GlobalRate: 1, GlobalBurst: 1, ChatRate: 100, ChatBurst: 100})
+
+	s.send(ctx, PriorityReply, 1, "первое")
+	s.release()
+	s.wantSent(t, 0, "первое")
+
+	// reply is queued earlier, but reminder takes the next token
+	s.send(ctx, PriorityReply, 1, "ответ")
+	s.send(ctx, PriorityReminder, 2, "напоминание")
+	s.release()
+	s.wantSent(t, 2)
+
+	s.clk.Advance(time.Second)
+	s.release()
+	s.wantSent(t, 1, "напоминание")
+
+	s.clk.Advance(time.Second)
+	s.release()
+	s.wantSent(t, 0, "ответ")
+}
+
+func TestCancelWhileQueued(t *testing.T) {
+	s := newInstance(t, Limits{GlobalRate: 1, GlobalBurst: 1, ChatRate: 100, ChatBurst: 100})
+
+	s.send(context.Background(), PriorityReply, 1, "первое")
+	s.release()
+	s.wantSent(t, 0, "первое")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := s.send(ctx, PriorityReply, 1, "отменено")
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendMessage of cancelled message: %v, want context.Canceled", err)
+	}
+
+	// cancelled message is removed from queue and does not take token of the next one
+	s.wantSent(t, 0)
+	s.send(context.Background(), PriorityReply, 1, "следующее")
+	s.clk.Advance(time.Second)
+	s.release()
+	s.wantSent(t, 0, "следующее")
+}
+
+func TestEditsAndCallbackAnswers(t *testing.T) {
+	ctx := context.Background()
+	s := newInstance(t, Limits{GlobalRate: 100, GlobalBurst: 100, ChatRate: 1, ChatBurst: 1})
+
+	done := s.send(ctx, PriorityReply, 1, "сообщение")
+	s.release()
+	s.wantSent(t, 0, "сообщение")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// answer to callback query does not wait for token of chat
+	answered := make(chan error, 1)
+	go func() {
+		_, err := s.AnswerCallbackQuery(ctx, PriorityReply, &bot.AnswerCallbackQueryParams{CallbackQueryID: "1"})
+		answered <- err
+	}()
+	<-s.wake
+	s.release()
+	if err := <-answered; err != nil {
+		t.Fatalf("AnswerCallbackQuery: %v", err)
+	}
+
+	// edit waits for token of chat like a new message
+	edited := make(chan error, 1)
+	go func() {
+		_, err := s.EditMessageText(ctx, PriorityReply, &bot.EditMessageTextParams{ChatID: 1, MessageID: 1, Text: "изменено"})
+		edited <- err
+	}()
+	<-s.wake
+	s.release()
+	if n := s.queued(); n != 1 {
+		t.Fatalf("%d messages queued, want edit", n)
+	}
+
+	s.clk.Advance(time.Second)
+	s.release()
+	if err := <-edited; err != nil {
+		t.Fatalf("EditMessageText: %v", err)
+	}
+	if call, err := s.api.Wait("editMessageText", 0); err != nil || call.Text() != "изменено" {
+		t.Errorf("editMessageText %q, %v", call.Text(), err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := newInstance(t, Limits{GlobalRate: 100, GlobalBurst: 100, ChatRate: 1, ChatBurst: 1})
+
+	first := s.send(ctx, PriorityReply, 1, "первое")
+	second := s.send(ctx, PriorityReply, 1, "второе")
+	go s.Run(ctx)
+
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	// Run sleeps until token of chat is refilled, also if clock moves before it sets timer
+	s.clk.Advance(time.Second)
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+
+	s.wantSent(t, 0, "первое", "второе")
+}
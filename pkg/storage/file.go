@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// NewFile returns Memory store that is loaded from JSON file and saved to it after every committed change.
+// File is replaced atomically via temporary file and rename, so it is never left half-written.
+func NewFile(path string) (*Memory, error) {
+	data, err := loadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Memory{root: &memoryRoot{data: data}}
+	m.root.persist = func(d *memoryData) error {
+		return saveFile(path, d)
+	}
+
+	return m, nil
+}
+
+func loadFile(path string) (*memoryData, error) {
+	file, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newMemoryData(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+
+	data := newMemoryData()
+	if err := json.Unmarshal(file, data); err != nil {
+		return nil, fmt.Errorf("ошибка разбора JSON: %w", err)
+	}
+
+	// maps are null in file saved without rows
+	empty := newMemoryData()
+	if data.Events == nil {
+		data.Events = empty.Events
+	}
+	if data.Notifications == nil {
+		data.Notifications = empty.Notifications
+	}
+	if data.Users == nil {
+		data.Users = empty.Users
+	}
+	if data.Dialogs == nil {
+		data.Dialogs = empty.Dialogs
+	}
+	if data.Outbox == nil {
+		data.Outbox = empty.Outbox
+	}
+	// file saved before per-chat counters continues from IDs of existing events
+	if data.LastUserEventIDs == nil {
+		data.LastUserEventIDs = empty.LastUserEventIDs
+		for _, e := range data.Events {
+			data.LastUserEventIDs[e.UserTgID] = max(data.LastUserEventIDs[e.UserTgID], e.UserEventID)
+		}
+	}
+
+	return data, nil
+}
+
+func saveFile(path string, d *memoryData) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,624 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+)
+
+// memoryData is a whole state of Memory store.
+type memoryData struct {
+	Events        map[int]db.Event
+	Notifications map[int]db.Notification
+	Users         map[int64]db.User
+	Dialogs       map[int64]db.Dialog
+	Outbox        map[int]db.Outbox
+	// LastUserEventIDs are last per-chat event IDs, like event_counters table they are not reused after deletion
+	LastUserEventIDs   map[int64]int
+	LastEventID        int
+	LastNotificationID int
+	LastOutboxID       int
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		Events:           make(map[int]db.Event),
+		Notifications:    make(map[int]db.Notification),
+		Users:            make(map[int64]db.User),
+		Dialogs:          make(map[int64]db.Dialog),
+		Outbox:           make(map[int]db.Outbox),
+		LastUserEventIDs: make(map[int64]int),
+	}
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		Events:             make(map[int]db.Event, len(d.Events)),
+		Notifications:      make(map[int]db.Notification, len(d.Notifications)),
+		Users:              make(map[int64]db.User, len(d.Users)),
+		Dialogs:            make(map[int64]db.Dialog, len(d.Dialogs)),
+		Outbox:             make(map[int]db.Outbox, len(d.Outbox)),
+		LastUserEventIDs:   make(map[int64]int, len(d.LastUserEventIDs)),
+		LastEventID:        d.LastEventID,
+		LastNotificationID: d.LastNotificationID,
+		LastOutboxID:       d.LastOutboxID,
+	}
+	for id, e := range d.Events {
+		c.Events[id] = copyEvent(e)
+	}
+	for id, n := range d.Notifications {
+		c.Notifications[id] = n
+	}
+	for id, u := range d.Users {
+		c.Users[id] = u
+	}
+	for id, dl := range d.Dialogs {
+		c.Dialogs[id] = dl
+	}
+	for id, o := range d.Outbox {
+		c.Outbox[id] = o
+	}
+	for chatID, id := range d.LastUserEventIDs {
+		c.LastUserEventIDs[chatID] = id
+	}
+
+	return c
+}
+
+// txAttempts is how many times transaction runs on a copy of state before it runs under the lock.
+const txAttempts = 3
+
+// memoryRoot is a state shared by Memory store and its transactions.
+type memoryRoot struct {
+	mu   sync.Mutex
+	data *memoryData
+	// version is incremented by every committed change, transaction commits only if it has not changed since its start.
+	version int
+	// persist is called with state after every committed change, used by file store.
+	persist func(*memoryData) error
+}
+
+// Memory is EventStore that keeps everything in memory, data is lost on restart.
+// Transactions are optimistic: changes are made on a copy of state without holding the lock, the copy
+// replaces state on commit if no other change was committed meanwhile, otherwise transaction is run again.
+// So transactions are serializable and claimed rows are delivered once within the process.
+type Memory struct {
+	root *memoryRoot
+	// tx is a state of running transaction, nil outside of transaction
+	tx *memoryData
+}
+
+func NewMemory() *Memory {
+	return &Memory{root: &memoryRoot{data: newMemoryData()}}
+}
+
+// read runs fn on current state.
+func (m *Memory) read(fn func(d *memoryData)) {
+	if m.tx != nil {
+		fn(m.tx)
+		return
+	}
+
+	m.root.mu.Lock()
+	defer m.root.mu.Unlock()
+	fn(m.root.data)
+}
+
+// write runs fn on current state and persists it outside of transaction.
+func (m *Memory) write(fn func(d *memoryData) error) error {
+	if m.tx != nil {
+		return fn(m.tx)
+	}
+
+	m.root.mu.Lock()
+	defer m.root.mu.Unlock()
+
+	if err := fn(m.root.data); err != nil {
+		return err
+	}
+
+	m.root.version++
+	return m.root.save()
+}
+
+func (r *memoryRoot) save() error {
+	if r.persist == nil {
+		return nil
+	}
+
+	return r.persist(r.data)
+}
+
+func (m *Memory) RunInTransaction(_ context.Context, fn func(EventStore) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	for range txAttempts - 1 {
+		m.root.mu.Lock()
+		tx, version := m.root.data.clone(), m.root.version
+		m.root.mu.Unlock()
+
+		if err := fn(&Memory{root: m.root, tx: tx}); err != nil {
+			return err
+		}
+
+		m.root.mu.Lock()
+		if m.root.version == version {
+			err := m.root.commit(tx)
+			m.root.mu.Unlock()
+			return err
+		}
+		m.root.mu.Unlock()
+	}
+
+	// the last attempt is not raced by other changes, so transaction completes under contention too
+	m.root.mu.Lock()
+	defer m.root.mu.Unlock()
+
+	tx := m.root.data.clone()
+	if err := fn(&Memory{root: m.root, tx: tx}); err != nil {
+		return err
+	}
+
+	return m.root.commit(tx)
+}
+
+// commit replaces state with state of transaction, it is called under the lock.
+func (r *memoryRoot) commit(tx *memoryData) error {
+	r.data = tx
+	r.version++
+	return r.save()
+}
+
+func (m *Memory) AddEvent(_ context.Context, event *db.Event) (*db.Event, error) {
+	err := m.write(func(d *memoryData) error {
+		d.LastEventID++
+		event.ID = d.LastEventID
+		d.LastUserEventIDs[event.UserTgID]++
+		event.UserEventID = d.LastUserEventIDs[event.UserTgID]
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+
+		d.Events[event.ID] = copyEvent(*event)
+		return nil
+	})
+
+	return event, err
+}
+
+func (m *Memory) UpdateEvent(_ context.Context, event *db.Event) (updated bool, err error) {
+	err = m.write(func(d *memoryData) error {
+		old, ok := d.Events[event.ID]
+		if !ok {
+			return nil
+		}
+
+		e := copyEvent(*event)
+		e.UserEventID, e.CreatedAt = old.UserEventID, old.CreatedAt
+		d.Events[event.ID] = e
+		updated = true
+		return nil
+	})
+
+	return updated, err
+}
+
+func (m *Memory) DeleteEvent(_ context.Context, id int) (deleted bool, err error) {
+	err = m.write(func(d *memoryData) error {
+		if _, ok := d.Events[id]; !ok {
+			return nil
+		}
+
+		delete(d.Events, id)
+		for nid, n := range d.Notifications {
+			if n.EventID == id {
+				delete(d.Notifications, nid)
+			}
+		}
+		for oid, o := range d.Outbox {
+			if o.EventID == id {
+				delete(d.Outbox, oid)
+			}
+		}
+		deleted = true
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (m *Memory) OneEvent(_ context.Context, search *db.EventSearch) (event *db.Event, err error) {
+	m.read(func(d *memoryData) {
+		if list := d.events(search); len(list) > 0 {
+			event = &list[0]
+		}
+	})
+
+	return event, nil
+}
+
+// ClaimEvent returns event like OneEvent, rows need no locking as transactions are serialized.
+func (m *Memory) ClaimEvent(ctx context.Context, search *db.EventSearch) (*db.Event, error) {
+	return m.OneEvent(ctx, search)
+}
+
+func (m *Memory) EventsByFilters(_ context.Context, search *db.EventSearch, pager db.Pager) (events []db.Event, err error) {
+	m.read(func(d *memoryData) {
+		events = d.events(search)
+	})
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].SendAt.Equal(events[j].SendAt) {
+			return events[i].SendAt.Before(events[j].SendAt)
+		}
+		return events[i].UserEventID < events[j].UserEventID
+	})
+
+	return paginate(events, pager), nil
+}
+
+func (m *Memory) CountEvents(_ context.Context, search *db.EventSearch) (count int, err error) {
+	m.read(func(d *memoryData) {
+		count = len(d.events(search))
+	})
+
+	return count, nil
+}
+
+func (m *Memory) EventHistory(_ context.Context, chatID int64, pager db.Pager) (events []db.Event, err error) {
+	m.read(func(d *memoryData) {
+		for _, e := range d.events(&db.EventSearch{UserTgID: &chatID}) {
+			if e.StatusID == db.EventStatusSent || e.StatusID == db.EventStatusFailed || e.SentAt != nil {
+				events = append(events, e)
+			}
+		}
+	})
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i].SentAt, events[j].SentAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.After(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return events[i].SendAt.After(events[j].SendAt)
+	})
+
+	return paginate(events, pager), nil
+}
+
+func (m *Memory) AddNotification(_ context.Context, notification *db.Notification) (*db.Notification, error) {
+	err := m.write(func(d *memoryData) error {
+		d.LastNotificationID++
+		notification.ID = d.LastNotificationID
+		if notification.CreatedAt.IsZero() {
+			notification.CreatedAt = time.Now()
+		}
+
+		n := *notification
+		n.Event = nil
+		d.Notifications[n.ID] = n
+		return nil
+	})
+
+	return notification, err
+}
+
+func (m *Memory) UpdateNotification(_ context.Context, notification *db.Notification) (updated bool, err error) {
+	err = m.write(func(d *memoryData) error {
+		old, ok := d.Notifications[notification.ID]
+		if !ok {
+			return nil
+		}
+
+		n := *notification
+		n.Event, n.CreatedAt = nil, old.CreatedAt
+		d.Notifications[n.ID] = n
+		updated = true
+		return nil
+	})
+
+	return updated, err
+}
+
+func (m *Memory) NotificationByID(_ context.Context, id int) (notification *db.Notification, err error) {
+	m.read(func(d *memoryData) {
+		if n, ok := d.Notifications[id]; ok {
+			notification = &n
+		}
+	})
+
+	return notification, nil
+}
+
+func (m *Memory) NotificationsByFilters(_ context.Context, search *db.NotificationSearch) (list []db.Notification, err error) {
+	m.read(func(d *memoryData) {
+		list = d.notifications(search)
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].SendAt.Before(list[j].SendAt)
+	})
+
+	return list, nil
+}
+
+// ClaimNotification returns notification like NotificationsByFilters, rows need no locking as transactions are serialized.
+func (m *Memory) ClaimNotification(_ context.Context, search *db.NotificationSearch) (notification *db.Notification, err error) {
+	m.read(func(d *memoryData) {
+		if list := d.notifications(search); len(list) > 0 {
+			notification = &list[0]
+		}
+	})
+
+	return notification, nil
+}
+
+func (m *Memory) AddOutbox(_ context.Context, outbox *db.Outbox) (*db.Outbox, error) {
+	err := m.write(func(d *memoryData) error {
+		d.LastOutboxID++
+		outbox.ID = d.LastOutboxID
+		if outbox.CreatedAt.IsZero() {
+			outbox.CreatedAt = time.Now()
+		}
+
+		o := *outbox
+		o.Event = nil
+		d.Outbox[o.ID] = o
+		return nil
+	})
+
+	return outbox, err
+}
+
+func (m *Memory) UpdateOutbox(_ context.Context, outbox *db.Outbox) (updated bool, err error) {
+	err = m.write(func(d *memoryData) error {
+		old, ok := d.Outbox[outbox.ID]
+		if !ok {
+			return nil
+		}
+
+		o := *outbox
+		o.Event, o.CreatedAt = nil, old.CreatedAt
+		d.Outbox[o.ID] = o
+		updated = true
+		return nil
+	})
+
+	return updated, err
+}
+
+func (m *Memory) OutboxesByFilters(_ context.Context, search *db.OutboxSearch) (list []db.Outbox, err error) {
+	m.read(func(d *memoryData) {
+		list = d.outbox(search)
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].NextAttemptAt.Before(list[j].NextAttemptAt)
+	})
+
+	return list, nil
+}
+
+// ClaimOutbox returns outbox message like OutboxesByFilters, rows need no locking as transactions are serialized.
+func (m *Memory) ClaimOutbox(_ context.Context, search *db.OutboxSearch) (outbox *db.Outbox, err error) {
+	m.read(func(d *memoryData) {
+		if list := d.outbox(search); len(list) > 0 {
+			outbox = &list[0]
+		}
+	})
+
+	return outbox, nil
+}
+
+func (m *Memory) UserByID(_ context.Context, id int64) (user *db.User, err error) {
+	m.read(func(d *memoryData) {
+		if u, ok := d.Users[id]; ok {
+			user = &u
+		}
+	})
+
+	return user, nil
+}
+
+func (m *Memory) SaveUser(_ context.Context, user *db.User) error {
+	return m.write(func(d *memoryData) error {
+		if old, ok := d.Users[user.ID]; ok {
+			user.CreatedAt = old.CreatedAt
+		} else if user.CreatedAt.IsZero() {
+			user.CreatedAt = time.Now()
+		}
+
+		d.Users[user.ID] = *user
+		return nil
+	})
+}
+
+func (m *Memory) DialogByID(_ context.Context, id int64) (dialog *db.Dialog, err error) {
+	m.read(func(d *memoryData) {
+		if dl, ok := d.Dialogs[id]; ok {
+			dialog = &dl
+		}
+	})
+
+	return dialog, nil
+}
+
+func (m *Memory) SaveDialog(_ context.Context, dialog *db.Dialog) error {
+	return m.write(func(d *memoryData) error {
+		if dialog.UpdatedAt.IsZero() {
+			dialog.UpdatedAt = time.Now()
+		}
+		d.Dialogs[dialog.ID] = *dialog
+		return nil
+	})
+}
+
+func (m *Memory) DeleteDialog(_ context.Context, id int64) error {
+	return m.write(func(d *memoryData) error {
+		delete(d.Dialogs, id)
+		return nil
+	})
+}
+
+// events returns copies of events matching search in no particular order.
+func (d *memoryData) events(search *db.EventSearch) []db.Event {
+	var list []db.Event
+	for _, e := range d.Events {
+		if matchEvent(e, search) {
+			list = append(list, copyEvent(e))
+		}
+	}
+
+	return list
+}
+
+// notifications returns notifications matching search with their events.
+func (d *memoryData) notifications(search *db.NotificationSearch) []db.Notification {
+	var list []db.Notification
+	for _, n := range d.Notifications {
+		if !matchNotification(n, search) {
+			continue
+		}
+
+		if e, ok := d.Events[n.EventID]; ok {
+			e = copyEvent(e)
+			n.Event = &e
+		}
+		list = append(list, n)
+	}
+
+	return list
+}
+
+// outbox returns outbox messages matching search with their events.
+func (d *memoryData) outbox(search *db.OutboxSearch) []db.Outbox {
+	var list []db.Outbox
+	for _, o := range d.Outbox {
+		if !matchOutbox(o, search) {
+			continue
+		}
+
+		if e, ok := d.Events[o.EventID]; ok {
+			e = copyEvent(e)
+			o.Event = &e
+		}
+		list = append(list, o)
+	}
+
+	return list
+}
+
+func matchEvent(e db.Event, s *db.EventSearch) bool {
+	if s == nil {
+		return true
+	}
+
+	switch {
+	case s.ID != nil && e.ID != *s.ID,
+		s.UserTgID != nil && e.UserTgID != *s.UserTgID,
+		s.UserEventID != nil && e.UserEventID != *s.UserEventID,
+		s.Message != nil && e.Message != *s.Message,
+		s.SendAt != nil && !e.SendAt.Equal(*s.SendAt),
+		s.Recurrence != nil && (e.Recurrence == nil || *e.Recurrence != *s.Recurrence),
+		s.StatusID != nil && e.StatusID != *s.StatusID,
+		s.SentAt != nil && (e.SentAt == nil || !e.SentAt.Equal(*s.SentAt)),
+		s.CreatedAt != nil && !e.CreatedAt.Equal(*s.CreatedAt),
+		len(s.IDs) > 0 && !slices.Contains(s.IDs, e.ID),
+		len(s.StatusIDs) > 0 && !slices.Contains(s.StatusIDs, e.StatusID),
+		s.MessageILike != nil && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(*s.MessageILike)),
+		s.SendAtFrom != nil && e.SendAt.Before(*s.SendAtFrom),
+		s.SendAtTo != nil && !e.SendAt.Before(*s.SendAtTo),
+		s.Tag != nil && !slices.Contains(e.Tags, *s.Tag),
+		len(s.TagsAny) > 0 && !intersects(e.Tags, s.TagsAny),
+		s.CreatorTgID != nil && (e.CreatorTgID == nil || *e.CreatorTgID != *s.CreatorTgID):
+		return false
+	}
+
+	return true
+}
+
+func matchNotification(n db.Notification, s *db.NotificationSearch) bool {
+	if s == nil {
+		return true
+	}
+
+	switch {
+	case s.ID != nil && n.ID != *s.ID,
+		s.EventID != nil && n.EventID != *s.EventID,
+		s.OffsetMinutes != nil && n.OffsetMinutes != *s.OffsetMinutes,
+		s.SendAt != nil && !n.SendAt.Equal(*s.SendAt),
+		s.StatusID != nil && n.StatusID != *s.StatusID,
+		s.SentAt != nil && (n.SentAt == nil || !n.SentAt.Equal(*s.SentAt)),
+		s.CreatedAt != nil && !n.CreatedAt.Equal(*s.CreatedAt),
+		len(s.IDs) > 0 && !slices.Contains(s.IDs, n.ID),
+		len(s.EventIDs) > 0 && !slices.Contains(s.EventIDs, n.EventID),
+		s.SendAtTo != nil && !n.SendAt.Before(*s.SendAtTo):
+		return false
+	}
+
+	return true
+}
+
+func matchOutbox(o db.Outbox, s *db.OutboxSearch) bool {
+	if s == nil {
+		return true
+	}
+
+	switch {
+	case s.ID != nil && o.ID != *s.ID,
+		s.EventID != nil && o.EventID != *s.EventID,
+		s.NotificationID != nil && (o.NotificationID == nil || *o.NotificationID != *s.NotificationID),
+		s.UserTgID != nil && o.UserTgID != *s.UserTgID,
+		s.Message != nil && o.Message != *s.Message,
+		s.SendAt != nil && !o.SendAt.Equal(*s.SendAt),
+		s.StatusID != nil && o.StatusID != *s.StatusID,
+		s.Attempts != nil && o.Attempts != *s.Attempts,
+		s.NextAttemptAt != nil && !o.NextAttemptAt.Equal(*s.NextAttemptAt),
+		s.LastError != nil && (o.LastError == nil || *o.LastError != *s.LastError),
+		s.SentAt != nil && (o.SentAt == nil || !o.SentAt.Equal(*s.SentAt)),
+		s.CreatedAt != nil && !o.CreatedAt.Equal(*s.CreatedAt),
+		len(s.IDs) > 0 && !slices.Contains(s.IDs, o.ID),
+		len(s.StatusIDs) > 0 && !slices.Contains(s.StatusIDs, o.StatusID),
+		s.NextAttemptAtTo != nil && !o.NextAttemptAt.Before(*s.NextAttemptAtTo):
+		return false
+	}
+
+	return true
+}
+
+func intersects(a, b []string) bool {
+	for _, v := range b {
+		if slices.Contains(a, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyEvent copies event with its slices, so stored event is not changed by caller.
+func copyEvent(e db.Event) db.Event {
+	e.Tags = append([]string(nil), e.Tags...)
+	e.Mentions = append([]string(nil), e.Mentions...)
+	return e
+}
+
+func paginate[T any](list []T, pager db.Pager) []T {
+	p := pager.Pager()
+	offset, limit := p.GetOffset(), p.GetLimit()
+	if offset >= len(list) {
+		return nil
+	}
+
+	list = list[offset:]
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+
+	return list
+}
@@ -0,0 +1,334 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+)
+
+// memoryStores returns memory store and file store in a temporary directory with function reloading the latter.
+func memoryStores(t *testing.T) []struct {
+	name   string
+	store  *storage.Memory
+	reload func() *storage.Memory
+} {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "events.json")
+	file, err := storage.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []struct {
+		name   string
+		store  *storage.Memory
+		reload func() *storage.Memory
+	}{
+		{"memory", storage.NewMemory(), nil},
+		{"file", file, func() *storage.Memory {
+			store, err := storage.NewFile(path)
+			if err != nil {
+				t.Fatalf("NewFile: %v", err)
+			}
+			return store
+		}},
+	}
+}
+
+func newEvent(chatID int64, message string) *db.Event {
+	return &db.Event{UserTgID: chatID, Message: message, SendAt: time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC), StatusID: db.EventStatusPending}
+}
+
+func TestMemoryConcurrentAddEvent(t *testing.T) {
+	const n = 20
+	ctx := context.Background()
+	chats := []int64{1, 2}
+
+	for _, tt := range memoryStores(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for _, chatID := range chats {
+				for range n {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := tt.store.AddEvent(ctx, newEvent(chatID, "событие")); err != nil {
+							t.Errorf("AddEvent: %v", err)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+
+			stores := []*storage.Memory{tt.store}
+			if tt.reload != nil {
+				stores = append(stores, tt.reload())
+			}
+			for _, store := range stores {
+				for _, chatID := range chats {
+					events, err := store.EventsByFilters(ctx, &db.EventSearch{UserTgID: &chatID}, db.PagerNoLimit)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					var ids []int
+					for _, e := range events {
+						ids = append(ids, e.UserEventID)
+					}
+					slices.Sort(ids)
+					for i, id := range ids {
+						if id != i+1 {
+							t.Fatalf("chat %d: UserEventID = %v, want 1..%d", chatID, ids, n)
+						}
+					}
+					if len(ids) != n {
+						t.Fatalf("chat %d: %d events, want %d", chatID, len(ids), n)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRollback(t *testing.T) {
+	const chatID = int64(1)
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	for _, tt := range memoryStores(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, err := tt.store.AddEvent(ctx, newEvent(chatID, "было"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tt.store.RunInTransaction(ctx, func(s storage.EventStore) error {
+				if _, err := s.AddEvent(ctx, newEvent(chatID, "добавлено")); err != nil {
+					return err
+				}
+				if _, err := s.DeleteEvent(ctx, kept.ID); err != nil {
+					return err
+				}
+				if err := s.SaveUser(ctx, &db.User{ID: chatID, Timezone: "Asia/Tokyo"}); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("RunInTransaction: %v, want %v", err, errRollback)
+			}
+
+			stores := []*storage.Memory{tt.store}
+			if tt.reload != nil {
+				stores = append(stores, tt.reload())
+			}
+			for _, store := range stores {
+				events, err := store.EventsByFilters(ctx, &db.EventSearch{}, db.PagerNoLimit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(events) != 1 || events[0].Message != "было" {
+					t.Errorf("events after rollback: %v, want only %q", events, "было")
+				}
+
+				if user, err := store.UserByID(ctx, chatID); err != nil || user != nil {
+					t.Errorf("user after rollback: %v, %v", user, err)
+				}
+			}
+
+			// IDs of rolled back rows are not taken
+			event, err := tt.store.AddEvent(ctx, newEvent(chatID, "после"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.UserEventID != 2 {
+				t.Errorf("UserEventID after rollback = %d, want 2", event.UserEventID)
+			}
+		})
+	}
+}
+
+func TestFileReload(t *testing.T) {
+	chatID := int64(1)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	store, err := storage.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recurrence := "FREQ=DAILY"
+	event, err := store.AddEvent(ctx, &db.Event{
+		UserTgID:   chatID,
+		Message:    "встреча",
+		SendAt:     time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC),
+		Recurrence: &recurrence,
+		Tags:       []string{"work"},
+		StatusID:   db.EventStatusPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := store.AddEvent(ctx, newEvent(chatID, "удалено"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.RunInTransaction(ctx, func(s storage.EventStore) error {
+		event.Message = "планёрка"
+		if _, err := s.UpdateEvent(ctx, event); err != nil {
+			return err
+		}
+		if _, err := s.DeleteEvent(ctx, deleted.ID); err != nil {
+			return err
+		}
+		_, err := s.AddNotification(ctx, &db.Notification{EventID: event.ID, OffsetMinutes: 60, SendAt: event.SendAt.Add(-time.Hour), StatusID: db.EventStatusPending})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveUser(ctx, &db.User{ID: chatID, Timezone: "Asia/Tokyo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := storage.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := reloaded.EventsByFilters(ctx, &db.EventSearch{UserTgID: &chatID}, db.PagerNoLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("reloaded events: %v, want 1", events)
+	}
+	got := events[0]
+	if got.ID != event.ID || got.UserEventID != 1 || got.Message != "планёрка" || !got.SendAt.Equal(event.SendAt) ||
+		got.Recurrence == nil || *got.Recurrence != recurrence || !slices.Equal(got.Tags, event.Tags) {
+		t.Errorf("reloaded event %+v, want %+v", got, *event)
+	}
+
+	notifications, err := reloaded.NotificationsByFilters(ctx, &db.NotificationSearch{EventID: &event.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].OffsetMinutes != 60 {
+		t.Errorf("reloaded notifications: %v", notifications)
+	}
+
+	if user, err := reloaded.UserByID(ctx, chatID); err != nil || user == nil || user.Timezone != "Asia/Tokyo" {
+		t.Errorf("reloaded user: %v, %v", user, err)
+	}
+
+	// reloaded store continues IDs
+	next, err := reloaded.AddEvent(ctx, newEvent(chatID, "новое"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= deleted.ID || next.UserEventID != 3 {
+		t.Errorf("new event ID=%d UserEventID=%d, want ID > %d and UserEventID 3", next.ID, next.UserEventID, deleted.ID)
+	}
+}
+
+func TestMemoryConcurrentClaim(t *testing.T) {
+	const n = 20
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	event, err := store.AddEvent(ctx, newEvent(1, "событие"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every transaction claims the pending event and marks it sent, only one of them finds it pending
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed int
+	)
+	pending := db.EventStatusPending
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var ok bool
+			err := store.RunInTransaction(ctx, func(s storage.EventStore) error {
+				ok = false
+				e, err := s.ClaimEvent(ctx, &db.EventSearch{ID: &event.ID, StatusID: &pending})
+				if err != nil || e == nil {
+					return err
+				}
+
+				e.StatusID = db.EventStatusSent
+				ok, err = s.UpdateEvent(ctx, e)
+				return err
+			})
+			if err != nil {
+				t.Errorf("RunInTransaction: %v", err)
+				return
+			}
+
+			if ok {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed != 1 {
+		t.Errorf("event claimed %d times, want 1", claimed)
+	}
+}
+
+func TestMemoryTransactionDoesNotBlockStore(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		runs := 0
+		done <- store.RunInTransaction(ctx, func(s storage.EventStore) error {
+			if _, err := s.AddEvent(ctx, newEvent(1, "в транзакции")); err != nil {
+				return err
+			}
+			if runs++; runs == 1 {
+				close(started)
+				<-release
+			}
+			return nil
+		})
+	}()
+	<-started
+
+	// store is not locked while transaction runs, its changes are not visible until commit
+	if _, err := store.AddEvent(ctx, newEvent(2, "вне транзакции")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.CountEvents(ctx, &db.EventSearch{}); err != nil || n != 1 {
+		t.Errorf("CountEvents during transaction = %d, %v, want 1", n, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+
+	// transaction was run again after conflicting change, so both events are kept
+	if n, err := store.CountEvents(ctx, &db.EventSearch{}); err != nil || n != 2 {
+		t.Errorf("CountEvents after commit = %d, %v, want 2", n, err)
+	}
+}
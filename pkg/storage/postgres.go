@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+)
+
+// Postgres is EventStore backed by PostgreSQL. Claimed rows are locked with SELECT ... FOR UPDATE SKIP LOCKED,
+// so several bot instances can share one database.
+type Postgres struct {
+	db   db.DB
+	repo db.EventsRepo
+	inTx bool
+}
+
+func NewPostgres(database db.DB) Postgres {
+	return Postgres{db: database, repo: db.NewEventsRepo(database.DB)}
+}
+
+func (p Postgres) RunInTransaction(ctx context.Context, fn func(EventStore) error) error {
+	if p.inTx {
+		return fn(p)
+	}
+
+	return p.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		return fn(Postgres{db: p.db, repo: p.repo.WithTransaction(tx), inTx: true})
+	})
+}
+
+func (p Postgres) AddEvent(ctx context.Context, event *db.Event) (*db.Event, error) {
+	return p.repo.AddUserEvent(ctx, event)
+}
+
+func (p Postgres) UpdateEvent(ctx context.Context, event *db.Event) (bool, error) {
+	return p.repo.UpdateEvent(ctx, event)
+}
+
+func (p Postgres) DeleteEvent(ctx context.Context, id int) (bool, error) {
+	return p.repo.DeleteEvent(ctx, id)
+}
+
+func (p Postgres) OneEvent(ctx context.Context, search *db.EventSearch) (*db.Event, error) {
+	return p.repo.OneEvent(ctx, search)
+}
+
+func (p Postgres) ClaimEvent(ctx context.Context, search *db.EventSearch) (*db.Event, error) {
+	return p.repo.OneEvent(ctx, search, db.ForUpdateSkipLocked())
+}
+
+func (p Postgres) EventsByFilters(ctx context.Context, search *db.EventSearch, pager db.Pager) ([]db.Event, error) {
+	return p.repo.EventsByFilters(ctx, search, pager, db.WithSort(
+		db.SortField{Column: db.Columns.Event.SendAt, Direction: db.SortAsc},
+		db.SortField{Column: db.Columns.Event.UserEventID, Direction: db.SortAsc},
+	))
+}
+
+func (p Postgres) CountEvents(ctx context.Context, search *db.EventSearch) (int, error) {
+	return p.repo.CountEvents(ctx, search)
+}
+
+func (p Postgres) EventHistory(ctx context.Context, chatID int64, pager db.Pager) ([]db.Event, error) {
+	search := &db.EventSearch{UserTgID: &chatID}
+	search.With("(?.? IN (?) OR ?.? IS NOT NULL)",
+		pg.Ident(db.Tables.Event.Alias), pg.Ident(db.Columns.Event.StatusID), pg.In([]int{db.EventStatusSent, db.EventStatusFailed}),
+		pg.Ident(db.Tables.Event.Alias), pg.Ident(db.Columns.Event.SentAt))
+
+	return p.repo.EventsByFilters(ctx, search, pager, db.WithSort(
+		db.SortField{Column: db.Columns.Event.SentAt, Direction: db.SortDescNullsLast},
+		db.SortField{Column: db.Columns.Event.SendAt, Direction: db.SortDesc},
+	))
+}
+
+func (p Postgres) AddNotification(ctx context.Context, notification *db.Notification) (*db.Notification, error) {
+	return p.repo.AddNotification(ctx, notification)
+}
+
+func (p Postgres) UpdateNotification(ctx context.Context, notification *db.Notification) (bool, error) {
+	return p.repo.UpdateNotification(ctx, notification)
+}
+
+func (p Postgres) NotificationByID(ctx context.Context, id int) (*db.Notification, error) {
+	return p.repo.NotificationByID(ctx, id)
+}
+
+func (p Postgres) NotificationsByFilters(ctx context.Context, search *db.NotificationSearch) ([]db.Notification, error) {
+	return p.repo.NotificationsByFilters(ctx, search, db.PagerNoLimit, p.repo.FullNotification(),
+		db.WithSort(db.SortField{Column: db.Columns.Notification.SendAt, Direction: db.SortAsc}))
+}
+
+func (p Postgres) ClaimNotification(ctx context.Context, search *db.NotificationSearch) (*db.Notification, error) {
+	return p.repo.OneNotification(ctx, search, p.repo.FullNotification(), db.ForUpdateSkipLocked())
+}
+
+func (p Postgres) AddOutbox(ctx context.Context, outbox *db.Outbox) (*db.Outbox, error) {
+	return p.repo.AddOutbox(ctx, outbox)
+}
+
+func (p Postgres) UpdateOutbox(ctx context.Context, outbox *db.Outbox) (bool, error) {
+	return p.repo.UpdateOutbox(ctx, outbox)
+}
+
+func (p Postgres) OutboxesByFilters(ctx context.Context, search *db.OutboxSearch) ([]db.Outbox, error) {
+	return p.repo.OutboxesByFilters(ctx, search, db.PagerNoLimit, p.repo.FullOutbox(),
+		db.WithSort(db.SortField{Column: db.Columns.Outbox.NextAttemptAt, Direction: db.SortAsc}))
+}
+
+func (p Postgres) ClaimOutbox(ctx context.Context, search *db.OutboxSearch) (*db.Outbox, error) {
+	return p.repo.OneOutbox(ctx, search, p.repo.FullOutbox(), db.ForUpdateSkipLocked())
+}
+
+func (p Postgres) UserByID(ctx context.Context, id int64) (*db.User, error) {
+	return p.repo.UserByID(ctx, id)
+}
+
+func (p Postgres) SaveUser(ctx context.Context, user *db.User) error {
+	updated, err := p.repo.UpdateUser(ctx, user)
+	if err != nil || updated {
+		return err
+	}
+
+	_, err = p.repo.AddUser(ctx, user)
+	return err
+}
+
+func (p Postgres) DialogByID(ctx context.Context, id int64) (*db.Dialog, error) {
+	return p.repo.DialogByID(ctx, id)
+}
+
+func (p Postgres) SaveDialog(ctx context.Context, dialog *db.Dialog) error {
+	_, err := p.repo.SaveDialog(ctx, dialog)
+	return err
+}
+
+func (p Postgres) DeleteDialog(ctx context.Context, id int64) error {
+	_, err := p.repo.DeleteDialog(ctx, id)
+	return err
+}
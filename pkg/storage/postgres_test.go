@@ -0,0 +1,52 @@
+package storage_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+	"github.com/kanef1/event-reminder-bot/pkg/storage"
+	"github.com/kanef1/event-reminder-bot/pkg/storage/storagetest"
+)
+
+func TestPostgresConcurrentAddEvent(t *testing.T) {
+	const chatID, n = int64(1), 20
+	ctx := context.Background()
+	store := storage.NewPostgres(storagetest.Postgres(t))
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids []int
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			event, err := store.AddEvent(ctx, &db.Event{UserTgID: chatID, Message: "событие", SendAt: time.Now(), StatusID: db.EventStatusPending})
+			if err != nil {
+				t.Errorf("AddEvent: %v", err)
+				return
+			}
+
+			mu.Lock()
+			ids = append(ids, event.UserEventID)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(ids)
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("UserEventID = %v, want 1..%d", ids, n)
+		}
+	}
+	if len(ids) != n {
+		t.Fatalf("added %d events, want %d", len(ids), n)
+	}
+}
@@ -1,102 +1,75 @@
+// Package storage provides EventStore implementations: PostgreSQL, in-memory and JSON file.
 package storage
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/kanef1/event-reminder-bot/pkg/model"
-	"log"
-	"os"
-	"sort"
-	"sync"
-	"time"
-)
 
-var (
-	filePath        = "events.json"
-	fileMu          sync.Mutex
-	remindersMu     sync.Mutex
-	activeReminders = make(map[int]context.CancelFunc)
+	"github.com/kanef1/event-reminder-bot/pkg/db"
 )
 
-func LoadEvents() ([]model.Event, error) {
-	fileMu.Lock()
-	defer fileMu.Unlock()
-
-	file, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []model.Event{}, nil
-		}
-		return nil, fmt.Errorf("ошибка чтения файла: %v", err)
-	}
-
-	var events []model.Event
-	if err := json.Unmarshal(file, &events); err != nil {
-		return nil, fmt.Errorf("ошибка разбора JSON: %v", err)
-	}
-	return events, nil
-}
-
-func SaveEvents(events []model.Event) error {
-	fileMu.Lock()
-	defer fileMu.Unlock()
-
-	data, err := json.MarshalIndent(events, "", "  ")
-	if err != nil {
-		return fmt.Errorf("ошибка сериализации: %v", err)
-	}
-
-	return os.WriteFile("events.json", data, 0644)
-}
-
-func CleanupPastEvents() error {
-	events, err := LoadEvents()
-	if err != nil {
-		return err
-	}
-
-	newEvents := make([]model.Event, 0)
-	for _, e := range events {
-		if e.DateTime.After(time.Now()) {
-			newEvents = append(newEvents, e)
-		} else {
-			log.Printf("Очистка прошедшего события ID=%d", e.ID)
-			CancelReminder(e.OriginalID)
-		}
-	}
-
-	return SaveEvents(ReindexEvents(newEvents))
-}
-
-func ReindexEvents(events []model.Event) []model.Event {
-	if len(events) == 0 {
-		return events
-	}
-
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].DateTime.Before(events[j].DateTime)
-	})
+// storage backends
+const (
+	BackendPostgres = "postgres"
+	BackendFile     = "file"
+	BackendMemory   = "memory"
+)
 
-	for i := range events {
-		events[i].ID = i + 1
-	}
-	return events
+// EventStore keeps events, their notifications, outbox of reminder messages, users and dialogs.
+// Searches support fields of db search structs, search conditions added by With are not supported.
+type EventStore interface {
+	// RunInTransaction runs fn with store working in one transaction, changes are discarded if fn returns error.
+	// fn may be run again if transaction conflicts with another one, so it must not have other side effects.
+	RunInTransaction(ctx context.Context, fn func(EventStore) error) error
+
+	// AddEvent adds event assigning ID and next per-chat UserEventID.
+	AddEvent(ctx context.Context, event *db.Event) (*db.Event, error)
+	UpdateEvent(ctx context.Context, event *db.Event) (bool, error)
+	DeleteEvent(ctx context.Context, id int) (bool, error)
+	// OneEvent returns event by search or nil.
+	OneEvent(ctx context.Context, search *db.EventSearch) (*db.Event, error)
+	// ClaimEvent returns event by search locked until the end of transaction,
+	// or nil if it does not exist or is locked by another transaction.
+	ClaimEvent(ctx context.Context, search *db.EventSearch) (*db.Event, error)
+	// EventsByFilters returns events sorted by SendAt and UserEventID.
+	EventsByFilters(ctx context.Context, search *db.EventSearch, pager db.Pager) ([]db.Event, error)
+	CountEvents(ctx context.Context, search *db.EventSearch) (int, error)
+	// EventHistory returns sent and failed events of chat and delivered occurrences of recurring ones, the latest first.
+	EventHistory(ctx context.Context, chatID int64, pager db.Pager) ([]db.Event, error)
+
+	AddNotification(ctx context.Context, notification *db.Notification) (*db.Notification, error)
+	UpdateNotification(ctx context.Context, notification *db.Notification) (bool, error)
+	NotificationByID(ctx context.Context, id int) (*db.Notification, error)
+	// NotificationsByFilters returns notifications with their events sorted by SendAt.
+	NotificationsByFilters(ctx context.Context, search *db.NotificationSearch) ([]db.Notification, error)
+	// ClaimNotification is ClaimEvent for notification, notification is returned with its event.
+	ClaimNotification(ctx context.Context, search *db.NotificationSearch) (*db.Notification, error)
+
+	AddOutbox(ctx context.Context, outbox *db.Outbox) (*db.Outbox, error)
+	UpdateOutbox(ctx context.Context, outbox *db.Outbox) (bool, error)
+	// OutboxesByFilters returns outbox messages with their events sorted by NextAttemptAt.
+	OutboxesByFilters(ctx context.Context, search *db.OutboxSearch) ([]db.Outbox, error)
+	// ClaimOutbox is ClaimEvent for outbox message, message is returned with its event.
+	ClaimOutbox(ctx context.Context, search *db.OutboxSearch) (*db.Outbox, error)
+
+	UserByID(ctx context.Context, id int64) (*db.User, error)
+	// SaveUser adds user or updates existing one.
+	SaveUser(ctx context.Context, user *db.User) error
+
+	DialogByID(ctx context.Context, id int64) (*db.Dialog, error)
+	// SaveDialog adds dialog or replaces existing one.
+	SaveDialog(ctx context.Context, dialog *db.Dialog) error
+	DeleteDialog(ctx context.Context, id int64) error
 }
 
-func CancelReminder(originalID int) {
-	remindersMu.Lock()
-	defer remindersMu.Unlock()
-
-	if cancel, exists := activeReminders[originalID]; exists {
-		cancel()
-		delete(activeReminders, originalID)
-		log.Printf("Напоминание OriginalID=%d отменено", originalID)
+// New returns store of file or memory backend. PostgreSQL store is created by NewPostgres from connection.
+func New(backend, file string) (EventStore, error) {
+	switch backend {
+	case BackendMemory:
+		return NewMemory(), nil
+	case BackendFile:
+		return NewFile(file)
 	}
-}
 
-func RegisterReminder(originalID int, cancel context.CancelFunc) {
-	remindersMu.Lock()
-	defer remindersMu.Unlock()
-	activeReminders[originalID] = cancel
+	return nil, fmt.Errorf("неизвестное хранилище %q", backend)
 }
@@ -0,0 +1,67 @@
+// Package storagetest provides PostgreSQL database for tests of storage users.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/kanef1/event-reminder-bot/pkg/db"
+)
+
+// EnvDatabaseURL is an environment variable with URL of database for tests, tests using Postgres are skipped if it is empty.
+const EnvDatabaseURL = "TEST_DATABASE_URL"
+
+// Postgres returns connection to a new schema created from docs/botsrv.sql in database of EnvDatabaseURL.
+// The schema is dropped when test finishes.
+func Postgres(t testing.TB) db.DB {
+	t.Helper()
+
+	url := os.Getenv(EnvDatabaseURL)
+	if url == "" {
+		t.Skipf("%s не задан", EnvDatabaseURL)
+	}
+
+	opts, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatalf("%s: %v", EnvDatabaseURL, err)
+	}
+
+	_, file, _, _ := runtime.Caller(0)
+	schemaSQL, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "docs", "botsrv.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := pg.Connect(opts)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA ?", pg.Ident(schema)); err != nil {
+		admin.Close()
+		t.Fatalf("создание схемы: %v", err)
+	}
+
+	opts.OnConnect = func(ctx context.Context, cn *pg.Conn) error {
+		_, err := cn.ExecContext(ctx, "SET search_path TO ?", pg.Ident(schema))
+		return err
+	}
+	conn := pg.Connect(opts)
+
+	t.Cleanup(func() {
+		conn.Close()
+		if _, err := admin.Exec("DROP SCHEMA ? CASCADE", pg.Ident(schema)); err != nil {
+			t.Errorf("удаление схемы: %v", err)
+		}
+		admin.Close()
+	})
+
+	if _, err := conn.Exec(string(schemaSQL)); err != nil {
+		t.Fatalf("создание таблиц: %v", err)
+	}
+
+	return db.New(conn)
+}
@@ -0,0 +1,403 @@
+// Package telegramtest provides an in-process fake Telegram Bot API server for end-to-end tests.
+//
+// Server serves the methods used by the bot: getMe, getUpdates (long polling), sendMessage, editMessageText,
+// editMessageReplyMarkup, answerCallbackQuery, getChatMember, setWebhook and deleteWebhook.
+// Every request is recorded, updates are injected with Message, Callback or PushUpdate.
+package telegramtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// Token is a bot token accepted by Server.
+const Token = "123456:TEST"
+
+// BotID is ID of the bot user returned by getMe.
+const BotID = 123456
+
+// maxPollTimeout limits getUpdates long polling, so bot notices stop quickly.
+const maxPollTimeout = 5 * time.Second
+
+// Call is a recorded API request.
+type Call struct {
+	Method string
+	Params map[string]string
+	// Message is a message sent or edited by call.
+	Message *models.Message
+}
+
+// ChatID returns chat_id parameter.
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params["chat_id"], 10, 64)
+	return id
+}
+
+// MessageID returns ID of message sent or edited by call.
+func (c Call) MessageID() int {
+	if c.Message != nil {
+		return c.Message.ID
+	}
+
+	id, _ := strconv.Atoi(c.Params["message_id"])
+	return id
+}
+
+// Text returns text parameter.
+func (c Call) Text() string {
+	return c.Params["text"]
+}
+
+// Keyboard returns inline keyboard of reply_markup parameter or nil.
+func (c Call) Keyboard() [][]models.InlineKeyboardButton {
+	var markup models.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(c.Params["reply_markup"]), &markup); err != nil {
+		return nil
+	}
+
+	return markup.InlineKeyboard
+}
+
+// Button returns callback data of inline button with text containing s or empty string.
+func (c Call) Button(s string) string {
+	for _, row := range c.Keyboard() {
+		for _, button := range row {
+			if strings.Contains(button.Text, s) {
+				return button.CallbackData
+			}
+		}
+	}
+
+	return ""
+}
+
+// failure is an error response injected by Fail.
+type failure struct {
+	code       int
+	text       string
+	retryAfter int
+}
+
+// Server is a fake Telegram Bot API.
+type Server struct {
+	srv *httptest.Server
+	me  models.User
+
+	mu           sync.Mutex
+	updates      []models.Update
+	lastUpdateID int64
+	lastMsgID    int
+	messages     map[int]models.Message
+	chats        map[int64]models.Chat
+	calls        []Call
+	// seen is a number of calls of method already returned by Wait
+	seen     map[string]int
+	members  map[[2]int64]models.ChatMemberType
+	failures map[string][]failure
+	// changed is closed and replaced on every update and call
+	changed chan struct{}
+}
+
+// NewServer starts fake API server, it is stopped by Close.
+func NewServer() *Server {
+	s := &Server{
+		me:       models.User{ID: BotID, IsBot: true, FirstName: "Test", Username: "test_bot"},
+		messages: make(map[int]models.Message),
+		chats:    make(map[int64]models.Chat),
+		seen:     make(map[string]int),
+		members:  make(map[[2]int64]models.ChatMemberType),
+		failures: make(map[string][]failure),
+		changed:  make(chan struct{}),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// URL returns server URL for bot.WithServerURL.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Username returns bot username.
+func (s *Server) Username() string {
+	return s.me.Username
+}
+
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// PushUpdate queues update for getUpdates assigning its ID.
+func (s *Server) PushUpdate(update models.Update) {
+	s.mu.Lock()
+	s.lastUpdateID++
+	update.ID = s.lastUpdateID
+	s.updates = append(s.updates, update)
+	s.notify()
+	s.mu.Unlock()
+}
+
+// Message sends text message from user to chat. Chat is private if chatID equals userID and supergroup otherwise.
+func (s *Server) Message(chatID, userID int64, text string) models.Message {
+	s.mu.Lock()
+	s.lastMsgID++
+	msg := models.Message{
+		ID:   s.lastMsgID,
+		From: &models.User{ID: userID, FirstName: "User" + strconv.FormatInt(userID, 10)},
+		Chat: chat(chatID, userID),
+		Date: int(time.Now().Unix()),
+		Text: text,
+	}
+	s.chats[chatID] = msg.Chat
+	if strings.HasPrefix(text, "/") {
+		cmd, _, _ := strings.Cut(text, " ")
+		msg.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeBotCommand, Length: len(cmd)}}
+	}
+	s.messages[msg.ID] = msg
+	s.mu.Unlock()
+
+	s.PushUpdate(models.Update{Message: &msg})
+	return msg
+}
+
+// Callback presses inline button with data under bot message by user.
+func (s *Server) Callback(messageID int, userID int64, data string) error {
+	s.mu.Lock()
+	msg, ok := s.messages[messageID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("message %d not found", messageID)
+	}
+
+	s.PushUpdate(models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:      strconv.Itoa(messageID) + ":" + data,
+		From:    models.User{ID: userID, FirstName: "User" + strconv.FormatInt(userID, 10)},
+		Message: models.MaybeInaccessibleMessage{Type: models.MaybeInaccessibleMessageTypeMessage, Message: &msg},
+		Data:    data,
+	}})
+	return nil
+}
+
+// SetMember sets status of user in chat returned by getChatMember, default status is member.
+func (s *Server) SetMember(chatID, userID int64, status models.ChatMemberType) {
+	s.mu.Lock()
+	s.members[[2]int64{chatID, userID}] = status
+	s.mu.Unlock()
+}
+
+// Fail makes next call of method fail with Telegram error code, e.g. 429 with retryAfter seconds.
+func (s *Server) Fail(method string, code int, description string, retryAfter int) {
+	s.mu.Lock()
+	s.failures[method] = append(s.failures[method], failure{code: code, text: description, retryAfter: retryAfter})
+	s.mu.Unlock()
+}
+
+// Calls returns recorded calls of method, all calls if method is empty.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// Wait returns the next call of method not returned by previous Wait, waiting for it until timeout.
+func (s *Server) Wait(method string, timeout time.Duration) (Call, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		s.mu.Lock()
+		n := 0
+		for _, c := range s.calls {
+			if c.Method != method {
+				continue
+			}
+			if n == s.seen[method] {
+				s.seen[method]++
+				s.mu.Unlock()
+				return c, nil
+			}
+			n++
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Call{}, fmt.Errorf("no %s call in %s", method, timeout)
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up waiting getUpdates and Wait. s.mu must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", 0)
+		return
+	}
+
+	params := make(map[string]string)
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		for k, v := range r.MultipartForm.Value {
+			params[k] = v[0]
+		}
+	}
+
+	if method == "getUpdates" {
+		s.getUpdates(w, r, params)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call := Call{Method: method, Params: params}
+	s.calls = append(s.calls, call)
+	last := &s.calls[len(s.calls)-1]
+	s.notify()
+
+	if f := s.failures[method]; len(f) > 0 {
+		s.failures[method] = f[1:]
+		writeError(w, f[0].code, f[0].text, f[0].retryAfter)
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, s.me)
+	case "sendMessage":
+		s.lastMsgID++
+		msg := models.Message{
+			ID:   s.lastMsgID,
+			From: &s.me,
+			Chat: s.chat(call.ChatID()),
+			Date: int(time.Now().Unix()),
+			Text: call.Text(),
+		}
+		if kb := call.Keyboard(); kb != nil {
+			msg.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: kb}
+		}
+		s.messages[msg.ID] = msg
+		last.Message = &msg
+		writeResult(w, msg)
+	case "editMessageText", "editMessageReplyMarkup":
+		msg, ok := s.messages[call.MessageID()]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Bad Request: message to edit not found", 0)
+			return
+		}
+		if method == "editMessageText" {
+			msg.Text = call.Text()
+		}
+		msg.ReplyMarkup = nil
+		if kb := call.Keyboard(); kb != nil {
+			msg.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: kb}
+		}
+		s.messages[msg.ID] = msg
+		last.Message = &msg
+		writeResult(w, msg)
+	case "getChatMember":
+		userID, _ := strconv.ParseInt(params["user_id"], 10, 64)
+		status, ok := s.members[[2]int64{call.ChatID(), userID}]
+		if !ok {
+			status = models.ChatMemberTypeMember
+		}
+		writeResult(w, map[string]any{"status": status, "user": models.User{ID: userID}})
+	case "answerCallbackQuery", "setWebhook", "deleteWebhook", "setMyCommands":
+		writeResult(w, true)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method "+method+" is not supported by fake server", 0)
+	}
+}
+
+// getUpdates returns updates after offset, waiting for them up to timeout parameter.
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, _ := strconv.ParseInt(params["offset"], 10, 64)
+	timeout, _ := strconv.Atoi(params["timeout"])
+	wait := min(time.Duration(timeout)*time.Second, maxPollTimeout)
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	for {
+		s.mu.Lock()
+		var updates []models.Update
+		for _, u := range s.updates {
+			if u.ID >= offset {
+				updates = append(updates, u)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeResult(w, updates)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			writeResult(w, []models.Update{})
+			return
+		case <-changed:
+		}
+	}
+}
+
+// chat returns chat of user messages or private chat.
+func (s *Server) chat(chatID int64) models.Chat {
+	if c, ok := s.chats[chatID]; ok {
+		return c
+	}
+
+	return chat(chatID, chatID)
+}
+
+func chat(chatID, userID int64) models.Chat {
+	if chatID == userID {
+		return models.Chat{ID: chatID, Type: models.ChatTypePrivate}
+	}
+
+	return models.Chat{ID: chatID, Type: models.ChatTypeSupergroup, Title: "Group " + strconv.FormatInt(chatID, 10)}
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, code int, description string, retryAfter int) {
+	resp := map[string]any{"ok": false, "error_code": code, "description": description}
+	if retryAfter > 0 {
+		resp["parameters"] = map[string]int{"retry_after": retryAfter}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}